@@ -108,7 +108,7 @@ func (g *Gateway) makeRelay(conn net.Conn, deviceID uint16) *relay2.Relay {
 
 	return relay2.New(g.Instance,
 		conn, deviceID,
-		g.KeepAlive,
+		relay2.KeepAlive(g.KeepAlive),
 		relay2.OfflineCallback(offlineCb),
 		relay2.Middlewares(PropertyLog(g.Devices)),
 	)
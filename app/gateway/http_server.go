@@ -43,7 +43,7 @@ func (g *Gateway) allDeviceLog(rw http.ResponseWriter, req *http.Request) {
 			DeviceCodeHex:   convcode.Dec2Hex(int(device.SubDeviceID)),
 			DeviceCodeAscii: device.SubDeviceID,
 			Address:         device.Conn.RemoteAddr().String(),
-			OnlineTime:      device.OnlineTime,
+			OnlineTime:      device.OnlineTime.Format("2006-01-02 15:04:05"),
 			Log:             log,
 		})
 	}
@@ -85,7 +85,7 @@ func (g *Gateway) getDeviceLog(rw http.ResponseWriter, req *http.Request) {
 		DeviceCodeHex:   convcode.Dec2Hex(int(device.SubDeviceID)),
 		DeviceCodeAscii: device.SubDeviceID,
 		Address:         device.Conn.RemoteAddr().String(),
-		OnlineTime:      device.OnlineTime,
+		OnlineTime:      device.OnlineTime.Format("2006-01-02 15:04:05"),
 		Log:             log,
 	}
 
@@ -6,10 +6,10 @@ import (
 )
 
 func PropertyLog(devices Devices) relay2.Middleware {
-	return func(relay *relay2.Relay, data relay2.Data) relay2.Data {
+	return func(relay *relay2.Relay, data relay2.Data) (relay2.Data, bool) {
 		if device, ok := devices[relay.SubDeviceID]; ok {
 			device.LRUCache.Add(time.Now().Format("2006-05-04 15:02:01")+string(data.PropertyType), &DataRecord{Data: data, Time: time.Now().Format("2006-05-04 15:02:01")})
 		}
-		return data
+		return data, true
 	}
 }
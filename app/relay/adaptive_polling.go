@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// 自适应询问的参数：询问间隔为平滑后响应时间的倍数，缩短时每次移动差值的 1/4
+const (
+	adaptiveLatencyFactor = 10
+	adaptiveAlpha         = 0.25
+	adaptiveTighten       = 4
+)
+
+// AdaptivePolling 自适应询问配置，询问间隔在 [min, max] 内随响应时间调整，代替 InquiryIntervals 与 KeepAlive 的询问间隔。
+// 响应变慢或上一次查询未收到响应时加大间隔（未响应时加倍），总线空闲、响应及时时逐步缩短；
+// 当前间隔见 Stats 的 PollInterval。只作用于默认协议的询问循环，上报间隔不变
+func AdaptivePolling(min, max time.Duration) Option {
+	return func(r *Relay) {
+		if max < min {
+			max = min
+		}
+		r.adaptive = &adaptivePoller{min: min, max: max, interval: min, observed: map[PropertyType]time.Time{}}
+	}
+}
+
+// 自适应询问间隔
+type adaptivePoller struct {
+	mu       sync.Mutex
+	min, max time.Duration
+	interval time.Duration
+	latency  time.Duration              // 平滑后的响应时间
+	observed map[PropertyType]time.Time // 已计入的查询发送时间，避免重复计入
+}
+
+// 当前询问间隔
+func (p *adaptivePoller) current() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+// 计入一次查询的响应时间
+func (p *adaptivePoller) observe(t PropertyType, sent time.Time, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.observed[t].Equal(sent) {
+		return
+	}
+	p.observed[t] = sent
+	if p.latency == 0 {
+		p.latency = latency
+	} else {
+		p.latency = time.Duration(adaptiveAlpha*float64(latency) + (1-adaptiveAlpha)*float64(p.latency))
+	}
+	target := p.clamp(adaptiveLatencyFactor * p.latency)
+	if target > p.interval {
+		p.interval = target
+		return
+	}
+	p.interval = p.clamp(p.interval - (p.interval-target)/adaptiveTighten)
+}
+
+// 查询未收到响应，间隔加倍
+func (p *adaptivePoller) backoff() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval = p.clamp(2 * p.interval)
+}
+
+func (p *adaptivePoller) clamp(d time.Duration) time.Duration {
+	if d < p.min {
+		return p.min
+	}
+	if d > p.max {
+		return p.max
+	}
+	return d
+}
+
+// 当前自适应询问器，未配置时为 nil
+func (r *Relay) adaptivePoller() *adaptivePoller {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.adaptive
+}
+
+// 收到 t 类型数据时计入对应查询的响应时间，需持有 mu
+func (r *Relay) observeLatency(t PropertyType, received time.Time) {
+	p := r.adaptivePoller()
+	if p == nil {
+		return
+	}
+	sent, ok := r.requested[t]
+	if !ok || sent.After(received) {
+		return
+	}
+	if latency := received.Sub(sent); latency < inFlightExpiry {
+		p.observe(t, sent, latency)
+	}
+}
+
+// 发送查询前检查上一次同类查询是否已响应，未响应时加大间隔
+func (r *Relay) checkAnswered(t PropertyType) {
+	p := r.adaptivePoller()
+	if p == nil {
+		return
+	}
+	r.mu.RLock()
+	sent, ok := r.requested[t]
+	answered := r.updated[t].After(sent)
+	r.mu.RUnlock()
+	if ok && !answered {
+		p.backoff()
+	}
+}
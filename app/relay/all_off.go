@@ -0,0 +1,56 @@
+package relay
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 下线前断开全部输出的写入超时
+const offOnDisconnectTimeout = time.Second
+
+// OffOnDisconnect 下线时先断开全部输出，连接仍可写时生效，写入失败只记录日志
+func OffOnDisconnect() Option {
+	return func(r *Relay) {
+		r.offOnDisconnect = true
+	}
+}
+
+// AllOff 断开全部输出，所有路数合并为一帧发送，写入失败时返回错误
+func (r *Relay) AllOff() error {
+	return r.AllOffContext(context.Background())
+}
+
+// AllOffContext 断开全部输出，ctx 取消时中断写入
+func (r *Relay) AllOffContext(ctx context.Context) error {
+	if err := r.SetOutputsContext(ctx, allOutputs(0)); err != nil {
+		return errors.Wrap(err, "all off failed")
+	}
+	return nil
+}
+
+// 下线前断开全部输出，连接可能已失效，不等待确认
+func (r *Relay) offBeforeDisconnect() {
+	if !r.offOnDisconnect || r.conn() == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), offOnDisconnectTimeout)
+	defer cancel()
+	routes := make([]uint8, 0, OutputRoutes)
+	for _, state := range allOutputs(0) {
+		routes = append(routes, state.Route)
+	}
+	if err := r.SetStateContext(ctx, OFF, routes...); err != nil {
+		r.log().Printf("设备 %d 下线前断开全部输出失败: %v", r.SubDeviceID, err)
+	}
+}
+
+// 全部路数设置为 value
+func allOutputs(value uint8) OutputStates {
+	states := make(OutputStates, 0, OutputRoutes)
+	for route := uint8(1); route <= OutputRoutes; route++ {
+		states = append(states, OutputState{Route: route, Value: value})
+	}
+	return states
+}
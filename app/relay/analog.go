@@ -0,0 +1,86 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// 单次读取寄存器数量上限
+const maxReadRegisters = 125
+
+// AnalogInput 模拟量输入，Value 为输入寄存器的原始值，换算为工程单位（如 4~20mA）需查阅设备手册
+type AnalogInput struct {
+	Register uint16 `json:"register"` // 输入寄存器地址
+	Value    uint16 `json:"value"`    // 原始值
+}
+
+// AnalogInputs 模拟量输入配置，上线后按 KeepAlive 间隔读取从 start 开始的 count 个输入寄存器，
+// 结果包含在 Snapshot 中；需要配置 Framing(FramingRTU) 或 Framing(FramingTCP)，被动模式不读取
+func AnalogInputs(start, count uint16) Option {
+	return func(r *Relay) {
+		r.analogStart = start
+		r.analogCount = count
+	}
+}
+
+// QueryAnalogInputs 读取从 start 开始的 count 个输入寄存器（功能码 04），ctx 控制写入与等待响应的时间
+func (r *Relay) QueryAnalogInputs(ctx context.Context, start, count uint16) ([]uint16, error) {
+	return r.QueryAnalogInputsAt(ctx, r.SubDeviceID, start, count)
+}
+
+// QueryAnalogInputsAt 通过本继电器的连接读取地址为 addr 的从站的输入寄存器，
+// addr 不是本继电器的 SubDeviceID 时结果不计入 Snapshot
+func (r *Relay) QueryAnalogInputsAt(ctx context.Context, addr uint16, start, count uint16) ([]uint16, error) {
+	if count == 0 || count > maxReadRegisters {
+		return nil, errors.Errorf("query analog inputs failed: count %d out of range [1, %d]", count, maxReadRegisters)
+	}
+	resp, err := r.call(ctx, ReadInputRegistersFrame(addr, start, count))
+	if err != nil {
+		return nil, errors.Wrap(err, "query analog inputs failed")
+	}
+	if int(resp[2]) != 2*int(count) {
+		return nil, errors.Errorf("query analog inputs failed: unexpected byte count %d", resp[2])
+	}
+	values := r.decodeRegisters(resp[3 : 3+resp[2]])
+	if addr == r.SubDeviceID {
+		r.saveAnalog(start, values)
+	}
+	return values, nil
+}
+
+// InquiryAnalogInputs 读取 AnalogInputs 配置的输入寄存器，超时 1 秒
+func (r *Relay) InquiryAnalogInputs() error {
+	ctx, cancel := context.WithTimeout(r.ctx, modbusResponseTimeout)
+	defer cancel()
+	_, err := r.QueryAnalogInputs(ctx, r.analogStart, r.analogCount)
+	return err
+}
+
+// AnalogInputState 获取最近一次读取的模拟量输入
+func (r *Relay) AnalogInputState() []AnalogInput {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]AnalogInput(nil), r.analog...)
+}
+
+// 保存读取结果，与已有的同地址寄存器合并，按地址排序
+func (r *Relay) saveAnalog(start uint16, values []uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := make([]AnalogInput, 0, len(r.analog)+len(values))
+	end := uint32(start) + uint32(len(values))
+	i := 0
+	for ; i < len(r.analog) && r.analog[i].Register < start; i++ {
+		next = append(next, r.analog[i])
+	}
+	for j, v := range values {
+		next = append(next, AnalogInput{Register: start + uint16(j), Value: v})
+	}
+	for ; i < len(r.analog); i++ {
+		if uint32(r.analog[i].Register) >= end {
+			next = append(next, r.analog[i])
+		}
+	}
+	r.analog = next
+}
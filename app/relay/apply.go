@@ -0,0 +1,30 @@
+package relay
+
+import (
+	"time"
+)
+
+// Apply 运行中修改配置，定时器在下一次触发时使用新值。
+// 可热更新的配置：KeepAlive、InquiryIntervals、AdaptivePolling、InquiryJitter、Middlewares、WithLogger、Calibration、ClampHumidity、SmoothTH；
+// 其余配置（如 FrameLength、Framing、Reconnect）只在 New 中生效，运行中修改需重新创建继电器。
+func (r *Relay) Apply(options ...Option) {
+	r.cfgMu.Lock()
+	defer r.cfgMu.Unlock()
+	for _, option := range options {
+		option(r)
+	}
+}
+
+// 当前日志
+func (r *Relay) log() Logger {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.logger
+}
+
+// 当前上报间隔
+func (r *Relay) keepAliveInterval() time.Duration {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.keepAlive
+}
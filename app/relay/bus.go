@@ -0,0 +1,118 @@
+package relay
+
+import (
+	"iot-sdk-go/sdk/device"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Bus 总线，多个子设备共用一个连接（如 RS-485 经 TCP 转串口网关），
+// 由总线统一读取数据帧，并按帧中的设备号分发给对应的继电器
+type Bus struct {
+	Conn net.Conn
+
+	frameLength int
+	logger      Logger
+
+	mu     sync.RWMutex
+	relays map[uint16]*Relay
+
+	writeLock chan struct{} // 串行化共享连接的写入
+}
+
+// NewBus 创建总线，帧长度默认 13 字节
+func NewBus(conn net.Conn) *Bus {
+	return &Bus{
+		Conn:        conn,
+		frameLength: DefaultFrameLength,
+		logger:      stdLogger{},
+		relays:      make(map[uint16]*Relay),
+		writeLock:   make(chan struct{}, 1),
+	}
+}
+
+// Relay 创建挂在总线上的继电器
+func (b *Bus) Relay(DeviceInstance *device.Device, subDeviceID uint16, options ...Option) *Relay {
+	r := New(DeviceInstance, b.Conn, subDeviceID, options...)
+	b.Add(r)
+	return r
+}
+
+// Add 将继电器挂到总线上，需在 Online 之前调用。
+// 总线上的继电器不再自己读取连接，下线时也不会关闭共享连接。
+func (b *Bus) Add(r *Relay) {
+	r.onBus = true
+	r.ownConn = false
+	r.writeLock = b.writeLock
+	b.mu.Lock()
+	b.relays[r.SubDeviceID] = r
+	b.mu.Unlock()
+}
+
+// Remove 从总线上移除继电器
+func (b *Bus) Remove(subDeviceID uint16) {
+	b.mu.Lock()
+	delete(b.relays, subDeviceID)
+	b.mu.Unlock()
+}
+
+// Run 开启一个协程，从共享连接中循环读取数据并分发，读取失败时所有继电器下线
+func (b *Bus) Run() error {
+	if b.Conn == nil {
+		return errors.Wrap(ErrNotConnected, "run bus failed")
+	}
+	go func() {
+		reader := newFrameReader(b.frameLength, verifyEnvelope)
+		for {
+			data, skipped, err := reader.next(b.Conn)
+			if skipped > 0 {
+				b.logger.Printf("总线重新对齐，丢弃 %d 字节", skipped)
+			}
+			if _, ok := err.(*frameError); ok {
+				b.logger.Printf("总线丢弃错误帧: %v", err)
+				continue
+			}
+			if err != nil {
+				b.logger.Printf("总线读取失败: %v", err)
+				b.offlineAll(errors.Wrap(err, "read bus failed"))
+				return
+			}
+			b.dispatch(data)
+		}
+	}()
+	return nil
+}
+
+// Close 关闭共享连接，所有继电器随之下线
+func (b *Bus) Close() error {
+	return b.Conn.Close()
+}
+
+// 按设备号分发
+func (b *Bus) dispatch(data []byte) {
+	id := uint16(data[1])<<8 | uint16(data[2])
+	b.mu.RLock()
+	r, ok := b.relays[id]
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if isClosed(r.closed) {
+		b.Remove(id)
+		return
+	}
+	r.handleFrame(data)
+}
+
+// 所有继电器因连接错误 err 下线
+func (b *Bus) offlineAll(err error) {
+	b.mu.Lock()
+	relays := b.relays
+	b.relays = make(map[uint16]*Relay)
+	b.mu.Unlock()
+	for _, r := range relays {
+		r.offlineWith(OfflineConnError, err)
+	}
+}
@@ -0,0 +1,39 @@
+package relay
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBusDispatch(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	bus := NewBus(server)
+	r1 := bus.Relay(nil, 0x1001, KeepAlive(time.Hour))
+	r2 := bus.Relay(nil, 0x1002, KeepAlive(time.Hour))
+	if err := bus.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+
+	writeFrames(t, client, []string{
+		"A0 10 02 1B 0F 00 00 00 00 00 00 00 A7",
+		"A0 10 01 AA 00 55 00 00 00 00 00 00 A7",
+	}, 2)
+	deadline := time.Now().Add(time.Second)
+	for len(r1.OutputState()) == 0 || len(r2.InputState()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("frames not dispatched")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(r1.InputState()) != 0 || len(r2.OutputState()) != 0 {
+		t.Fatal("frame dispatched to wrong relay")
+	}
+
+	r1.Offline()
+	if _, err := client.Write([]byte{0}); err != nil {
+		t.Fatal("shared conn closed by relay offline")
+	}
+}
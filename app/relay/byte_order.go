@@ -0,0 +1,29 @@
+package relay
+
+import (
+	"encoding/binary"
+)
+
+// ByteOrder 寄存器值的字节序配置，默认 binary.BigEndian（Modbus 标准）。
+// 用于读写保持寄存器等多字节值；默认协议的温湿度按单字节解析，不受影响
+func ByteOrder(order binary.ByteOrder) Option {
+	return func(r *Relay) {
+		r.byteOrder = order
+	}
+}
+
+// 按字节序解析寄存器值，每两个字节一个寄存器
+func (r *Relay) decodeRegisters(b []byte) []uint16 {
+	values := make([]uint16, len(b)/2)
+	for i := range values {
+		values[i] = r.byteOrder.Uint16(b[2*i:])
+	}
+	return values
+}
+
+// 将寄存器值转换为按大端写入帧后与设备字节序一致的值
+func (r *Relay) encodeRegister(value uint16) uint16 {
+	var b [2]byte
+	r.byteOrder.PutUint16(b[:], value)
+	return binary.BigEndian.Uint16(b[:])
+}
@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 错误、事件、数据队列长度
+const (
+	errorQueueSize = 16
+	eventQueueSize = 64
+	dataQueueSize  = 64
+)
+
+// EventType 事件类型枚举
+type EventType string
+
+// EventOnline 上线事件
+const EventOnline EventType = "ONLINE"
+
+// EventOffline 下线事件
+const EventOffline EventType = "OFFLINE"
+
+// EventReconnect 重连成功事件
+const EventReconnect EventType = "RECONNECT"
+
+// EventPropertyUpdate 属性更新事件，Payload 为 Data
+const EventPropertyUpdate EventType = "PROPERTY_UPDATE"
+
+// EventBreaker 熔断器状态变化事件，Payload 为 BreakerState
+const EventBreaker EventType = "BREAKER"
+
+// Event 继电器事件
+type Event struct {
+	Type        EventType
+	SubDeviceID uint16
+	Time        time.Time
+	Payload     interface{}
+	Labels      map[uint8]string // 输出、输入状态更新时为对应路数名称
+}
+
+// Errors 返回读写过程中产生的错误。
+// 解析失败、校验失败、写入失败等非致命错误发送后继电器继续运行；
+// 读取失败且无法重连属于致命错误，发送后继电器下线。
+// 通道带缓冲，消费不及时的错误会被丢弃并记录日志，Offline 时关闭。
+func (r *Relay) Errors() <-chan error {
+	return r.errs
+}
+
+// Events 返回继电器事件流，包含上线、下线、重连、属性更新和熔断器状态变化。
+// 通道带缓冲，消费不及时的事件会被丢弃，Offline 时发送下线事件后关闭。
+func (r *Relay) Events() <-chan Event {
+	return r.events
+}
+
+// Data 返回解码后的数据流，每收到一帧数据，经过中间件处理后发送一次，中间件终止的数据不发送。
+// 第一次调用后才开始发送；每帧的中间件只执行一次，结果同时用于该帧之后的第一次属性上报或 Get*，
+// 有状态的中间件（如 DedupMiddleware、RateLimitMiddleware）不会因为订阅了数据流而丢弃上报。
+// 通道带缓冲，长度 64，消费不及时的数据会被丢弃并计入 Stats 的 DataDropped，Offline 时关闭。
+func (r *Relay) Data() <-chan Data {
+	atomic.StoreInt32(&r.dataOn, 1)
+	return r.data
+}
+
+// 发送错误，队列满时丢弃
+func (r *Relay) reportError(err error) {
+	r.chanMu.Lock()
+	defer r.chanMu.Unlock()
+	r.lastErr, r.lastErrAt = err, r.now()
+	if r.chanClosed {
+		return
+	}
+	select {
+	case r.errs <- err:
+	default:
+		r.log().Printf("设备 %d 错误队列已满，丢弃错误: %v", r.SubDeviceID, err)
+	}
+}
+
+// 发送事件，队列满时丢弃
+func (r *Relay) emit(t EventType, payload interface{}) {
+	r.chanMu.Lock()
+	defer r.chanMu.Unlock()
+	if r.chanClosed {
+		return
+	}
+	e := Event{Type: t, SubDeviceID: r.SubDeviceID, Time: r.now(), Payload: payload}
+	if data, ok := payload.(Data); ok {
+		e.Labels = r.labels(data.PropertyType)
+	}
+	select {
+	case r.events <- e:
+	default:
+	}
+}
+
+// 发送解码后的数据，未调用 Data 时不发送，队列满时丢弃。
+// 中间件的处理结果留给该帧之后的第一次获取（上报或 Get*）使用，每帧只执行一次中间件
+func (r *Relay) publish(data Data) {
+	if atomic.LoadInt32(&r.dataOn) == 0 {
+		return
+	}
+	t, at := data.PropertyType, data.Time
+	data, ok := r.applyMiddlewares(data)
+	r.saveProcessed(t, at, data, ok)
+	if !ok {
+		return
+	}
+	r.chanMu.Lock()
+	defer r.chanMu.Unlock()
+	if r.chanClosed {
+		return
+	}
+	select {
+	case r.data <- data:
+	default:
+		count(&r.counters.dataDropped)
+	}
+}
+
+// 关闭错误、事件和数据通道
+func (r *Relay) closeChannels() {
+	r.chanMu.Lock()
+	defer r.chanMu.Unlock()
+	if !r.chanClosed {
+		r.chanClosed = true
+		close(r.errs)
+		close(r.events)
+		close(r.data)
+	}
+}
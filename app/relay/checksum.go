@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"bytes"
+)
+
+// Checksum 帧校验算法，校验值位于帧尾
+type Checksum interface {
+	Size() int              // 校验值字节数
+	Sum(data []byte) []byte // 计算 data 的校验值
+}
+
+// WithChecksum 接收帧校验算法配置，帧尾 Size() 字节为校验值，默认只校验帧头帧尾。
+// 只用于默认协议的定长帧，Framing(FramingRTU) 按 Modbus 规范固定使用 CRC-16/Modbus，Framing(FramingTCP) 没有校验值
+func WithChecksum(c Checksum) Option {
+	return func(r *Relay) {
+		r.verifyFrame = verifyChecksum(c)
+	}
+}
+
+// AppendChecksum 在帧尾追加校验值
+func AppendChecksum(c Checksum, frame []byte) []byte {
+	return append(frame, c.Sum(frame)...)
+}
+
+// 按函数实现的校验算法
+type checksumFunc struct {
+	size int
+	sum  func(data []byte) []byte
+}
+
+func (c checksumFunc) Size() int {
+	return c.size
+}
+
+func (c checksumFunc) Sum(data []byte) []byte {
+	return c.sum(data)
+}
+
+// ModbusCRC CRC-16/Modbus，低字节在前
+func ModbusCRC() Checksum {
+	return checksumFunc{2, func(data []byte) []byte {
+		crc := CRC16(data)
+		return []byte{byte(crc), byte(crc >> 8)}
+	}}
+}
+
+// CCITTCRC CRC-16/CCITT-FALSE（多项式 0x1021，初始值 0xFFFF），高字节在前
+func CCITTCRC() Checksum {
+	return checksumFunc{2, func(data []byte) []byte {
+		crc := uint16(0xFFFF)
+		for _, b := range data {
+			crc ^= uint16(b) << 8
+			for i := 0; i < 8; i++ {
+				if crc&0x8000 != 0 {
+					crc = crc<<1 ^ 0x1021
+				} else {
+					crc <<= 1
+				}
+			}
+		}
+		return []byte{byte(crc >> 8), byte(crc)}
+	}}
+}
+
+// XORChecksum 单字节异或校验
+func XORChecksum() Checksum {
+	return checksumFunc{1, func(data []byte) []byte {
+		var sum byte
+		for _, b := range data {
+			sum ^= b
+		}
+		return []byte{sum}
+	}}
+}
+
+// SumChecksum 单字节累加和校验，取低 8 位
+func SumChecksum() Checksum {
+	return checksumFunc{1, func(data []byte) []byte {
+		var sum byte
+		for _, b := range data {
+			sum += b
+		}
+		return []byte{sum}
+	}}
+}
+
+// 按校验算法校验帧尾
+func verifyChecksum(c Checksum) func(frame []byte) error {
+	return func(frame []byte) error {
+		n := len(frame) - c.Size()
+		if n < 2 {
+			return protocolErrorf(ErrShortFrame, "frame too short: % X", frame)
+		}
+		want := c.Sum(frame[:n])
+		if got := frame[n:]; !bytes.Equal(want, got) {
+			return protocolErrorf(ErrCRCMismatch, "checksum mismatch: want % X, got % X", want, got)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package relay
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	data := []byte("123456789")
+	cases := []struct {
+		name string
+		c    Checksum
+		want []byte
+	}{
+		{"modbus", ModbusCRC(), []byte{0x37, 0x4B}},
+		{"ccitt", CCITTCRC(), []byte{0x29, 0xB1}},
+		{"xor", XORChecksum(), []byte{0x31}},
+		{"sum", SumChecksum(), []byte{0xDD}},
+	}
+	for _, c := range cases {
+		if got := c.c.Sum(data); !bytes.Equal(got, c.want) || len(got) != c.c.Size() {
+			t.Fatalf("%s sum = % X, want % X", c.name, got, c.want)
+		}
+		frame := AppendChecksum(c.c, append([]byte{}, data...))
+		verify := verifyChecksum(c.c)
+		if err := verify(frame); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		frame[0] ^= 0x01
+		if err := verify(frame); err == nil {
+			t.Fatalf("%s: corrupted frame passed", c.name)
+		}
+	}
+}
+
+func TestWithChecksum(t *testing.T) {
+	r := New(nil, nil, 0x1001, WithChecksum(XORChecksum()))
+	frame := AppendChecksum(XORChecksum(), []byte{0xA0, 0x10, 0x01, 0x1B, 0x0F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	if err := r.verifyFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	frame[4] = 0x0E
+	if err := r.verifyFrame(frame); err == nil {
+		t.Fatal("bad checksum passed")
+	}
+}
@@ -0,0 +1,184 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	// BreakerClosed 正常询问
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 连续失败，冷却期内暂停询问
+	BreakerOpen
+	// BreakerHalfOpen 冷却结束，试探询问，成功后关闭，失败后重新打开
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker 熔断配置，连续 threshold 次询问失败后暂停询问 cooldown，之后试探询问，
+// 收到响应时恢复，仍失败时再暂停 cooldown；threshold <= 0 不熔断（默认）。
+// 询问返回错误（Modbus 超时、异常响应、写入失败）或默认协议的上一次同类查询未收到响应都算失败。
+// 状态变化时发送 EventBreaker 事件。只影响询问循环（包括 WithScheduler），
+// 上报、心跳和手动命令（InquiryTH、QueryTH 等）不受影响，手动查询收到响应同样使熔断器恢复
+func CircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(r *Relay) {
+		if threshold <= 0 {
+			r.breaker = nil
+			return
+		}
+		r.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown, pending: map[PropertyType]bool{}}
+	}
+}
+
+// 熔断器
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int                   // 连续失败次数
+	openedAt time.Time             // 最近一次打开的时间
+	pending  map[PropertyType]bool // 已发送、尚未收到响应的默认协议查询
+}
+
+// BreakerState 熔断器当前状态，未配置 CircuitBreaker 时总是 BreakerClosed
+func (r *Relay) BreakerState() BreakerState {
+	b := r.breaker
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// 询问循环执行一次询问，熔断器打开时跳过；手动查询不经过熔断器
+func (r *Relay) poll(wf WriteFn) {
+	if wf.t != "" {
+		r.breakerCheck(wf.t)
+	}
+	if !r.breakerAllow() {
+		return
+	}
+	err := wf.fn()
+	if err != nil {
+		r.breakerResult(false)
+	} else if r.framing != FramingVendor {
+		r.breakerResult(true)
+	}
+	r.writeResult(err)
+}
+
+// 是否允许询问，冷却结束时转为半开
+func (r *Relay) breakerAllow() bool {
+	b := r.breaker
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen {
+		if r.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		r.setBreakerState(BreakerHalfOpen)
+	}
+	return true
+}
+
+// 记录一次询问结果
+func (r *Relay) breakerResult(ok bool) {
+	b := r.breaker
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r.recordBreaker(ok)
+}
+
+// 询问前检查上一次同类默认协议查询是否已响应，未响应计为失败
+func (r *Relay) breakerCheck(t PropertyType) {
+	b := r.breaker
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending[t] {
+		delete(b.pending, t)
+		r.recordBreaker(false)
+	}
+}
+
+// 默认协议查询已发送，等待响应
+func (r *Relay) breakerSent(t PropertyType) {
+	b := r.breaker
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.pending[t] = true
+	b.mu.Unlock()
+}
+
+// 收到 t 类型数据，对应的查询计为成功
+func (r *Relay) breakerReceived(t PropertyType) {
+	b := r.breaker
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending[t] {
+		delete(b.pending, t)
+		r.recordBreaker(true)
+	}
+}
+
+// 计入询问结果并转换状态，需持有 breaker.mu
+func (r *Relay) recordBreaker(ok bool) {
+	b := r.breaker
+	if ok {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			r.setBreakerState(BreakerClosed)
+		}
+		return
+	}
+	if b.state == BreakerOpen {
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.failures = 0
+		b.openedAt = r.now()
+		r.setBreakerState(BreakerOpen)
+	}
+}
+
+// 转换状态并发送事件，打开或半开时清空等待中的查询，需持有 breaker.mu
+func (r *Relay) setBreakerState(s BreakerState) {
+	b := r.breaker
+	b.state = s
+	if s != BreakerClosed {
+		b.pending = map[PropertyType]bool{}
+	}
+	r.log().Printf("设备 %d 熔断器 %s", r.SubDeviceID, s)
+	r.emit(EventBreaker, s)
+}
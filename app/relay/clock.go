@@ -0,0 +1,40 @@
+package relay
+
+import "time"
+
+// Clock 时间源，默认为系统时钟，测试时可用 relaytest.FakeClock 代替
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer 定时器，与 time.Timer 相同，放弃等待时应调用 Stop。
+// 定义为别名，其他包的时钟声明相同的接口即可实现 Clock，不需要导入本包
+type Timer = interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// 系统时钟
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// WithClock 时间源配置，作用于创建时间、收到数据的时间、心跳、去抖、脉冲、询问与上报间隔、重连退避等；
+// 连接的读写截止时间与共享的 Scheduler 仍使用系统时钟
+func WithClock(c Clock) Option {
+	return func(r *Relay) {
+		r.clock = c
+	}
+}
+
+// 当前时间
+func (r *Relay) now() time.Time {
+	return r.clock.Now()
+}
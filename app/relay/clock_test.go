@@ -0,0 +1,203 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"relay/app/relay/relaytest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatFakeClock(t *testing.T) {
+	clock := relaytest.NewFakeClock(time.Now())
+	r := New(nil, relaytest.NewFakeConn(), 0x1001, PassiveMode(), WithLogger(nopLogger{}), WithClock(clock), HeartbeatTimeout(20*time.Millisecond))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	// 每半个超时检查一次，10ms 时仍存活
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Millisecond)
+	clock.BlockUntil(1)
+	if isClosed(r.Done()) {
+		t.Fatal("offline before heartbeat timeout")
+	}
+	clock.Advance(15 * time.Millisecond)
+	select {
+	case <-r.Done():
+	case <-time.After(time.Second):
+		t.Fatal("relay not offline after heartbeat timeout")
+	}
+}
+
+func TestPulseFakeClock(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	clock := relaytest.NewFakeClock(time.Now())
+	r := New(nil, server, 0x1001, WithClock(clock))
+	defer stop(r)
+	frames := make(chan []byte, 2)
+	go func() {
+		for {
+			b := make([]byte, 13)
+			if _, err := client.Read(b); err != nil {
+				return
+			}
+			frames <- b
+		}
+	}()
+	if err := r.PulseOutput(3, 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-frames; b[6] != 0x01 {
+		t.Fatalf("pulse frame % X", b)
+	}
+	clock.BlockUntil(1)
+	select {
+	case b := <-frames:
+		t.Fatalf("pulse restored before duration: % X", b)
+	default:
+	}
+	clock.Advance(time.Hour)
+	select {
+	case b := <-frames:
+		if b[6] != 0x02 {
+			t.Fatalf("restore frame % X", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pulse not restored")
+	}
+}
+
+func TestInquiryJitter(t *testing.T) {
+	r := New(nil, nil, 0x1001, InquiryJitter(0.2))
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		d := r.jittered(time.Second)
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("jittered interval %v out of ±20%%", d)
+		}
+		seen[d] = true
+		if delay := r.initialDelay(time.Second); delay < 0 || delay >= 200*time.Millisecond {
+			t.Fatalf("initial delay %v", delay)
+		}
+	}
+	if len(seen) < 2 {
+		t.Fatal("interval not randomized")
+	}
+	// 比例超过上限时取上限，间隔不会接近 0
+	r = New(nil, nil, 0x1001, InquiryJitter(1))
+	for i := 0; i < 100; i++ {
+		if d := r.jittered(time.Second); d < 100*time.Millisecond {
+			t.Fatalf("jittered interval %v below 10%%", d)
+		}
+	}
+
+	clock := relaytest.NewFakeClock(time.Now())
+	conn := relaytest.NewFakeConn()
+	r = New(nil, conn, 0x1001, KeepAlive(time.Second), WithLogger(nopLogger{}), WithClock(clock), InquiryJitter(0.5))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	// 温湿度与输入状态的询问都在等待第一次的随机延迟
+	clock.BlockUntil(2)
+	if n := len(conn.Writes()); n != 0 {
+		t.Fatalf("%d inquiries before initial delay", n)
+	}
+	clock.Advance(500 * time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for len(conn.Writes()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d inquiries after initial delay", len(conn.Writes()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := relaytest.NewFakeClock(time.Now())
+	conn := relaytest.NewFakeConn()
+	r := New(nil, conn, 0x1001, WithLogger(nopLogger{}), WithClock(clock), CircuitBreaker(2, time.Minute))
+	var states []BreakerState
+	drain := func() {
+		for {
+			select {
+			case e := <-r.Events():
+				if e.Type == EventBreaker {
+					states = append(states, e.Payload.(BreakerState))
+				}
+			default:
+				return
+			}
+		}
+	}
+	calls := 0
+	wf := WriteFn{t: TH, fn: func() error {
+		calls++
+		return r.InquiryTH()
+	}}
+
+	// 默认协议：连续两次查询未响应后打开，冷却期内不再发送
+	for i := 0; i < 4; i++ {
+		r.poll(wf)
+	}
+	if r.BreakerState() != BreakerOpen || calls != 2 || len(conn.Writes()) != 2 {
+		t.Fatalf("state %s, %d calls, %d writes", r.BreakerState(), calls, len(conn.Writes()))
+	}
+	if r.Health().Status != HealthDegraded {
+		t.Fatal("health not degraded while breaker open")
+	}
+
+	// 冷却结束后试探，收到响应后关闭
+	clock.Advance(time.Minute)
+	r.poll(wf)
+	if calls != 3 || r.BreakerState() != BreakerHalfOpen || len(conn.Writes()) != 3 {
+		t.Fatalf("probe: %d calls, state %s, %d writes", calls, r.BreakerState(), len(conn.Writes()))
+	}
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	if r.BreakerState() != BreakerClosed {
+		t.Fatalf("state %s after response", r.BreakerState())
+	}
+
+	// 询问返回错误同样计为失败，半开时失败重新打开
+	failing := WriteFn{fn: func() error { return ErrNotConnected }}
+	r.poll(failing)
+	r.poll(failing)
+	clock.Advance(time.Minute)
+	r.poll(failing)
+	drain()
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerClosed, BreakerOpen, BreakerHalfOpen, BreakerOpen}
+	if len(states) != len(want) {
+		t.Fatalf("states %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("states %v, want %v", states, want)
+		}
+	}
+
+	// 熔断器打开时手动查询照常发送，收到响应后恢复
+	result := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := r.QueryTH(ctx)
+		result <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(conn.Writes()) != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("query not sent while breaker open")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r.SaveTH(frame)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if r.BreakerState() != BreakerClosed {
+		t.Fatalf("state %s after manual query response", r.BreakerState())
+	}
+}
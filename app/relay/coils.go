@@ -0,0 +1,114 @@
+package relay
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// 连续的一段线圈，values[i] 对应第 start+i 路
+type coilRun struct {
+	start  uint8
+	values []bool
+}
+
+// 通过 Modbus 写线圈设置输出，第 N 路对应线圈 N-1。
+// 连续的路数用写多个线圈（功能码 0F）一帧写入，中间未指定的路数按已知输出状态补齐；
+// 设备不支持功能码 0F 时逐路写单个线圈（功能码 05）。每帧等待回显，超时 1 秒
+func (r *Relay) writeCoils(ctx context.Context, addr uint16, values map[uint8]uint8, current OutputStates) error {
+	for _, run := range coilRuns(values, current) {
+		err := r.callTimeout(ctx, WriteCoilsFrame(addr, uint16(run.start-1), run.values))
+		if e, ok := err.(*ModbusException); ok && e.Code == exceptionIllegalFunction {
+			return r.writeCoilsOneByOne(ctx, addr, values)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetOutputsAt 通过本继电器的连接设置地址为 addr 的 Modbus 从站的输出，写入方式与 SetOutputs 相同。
+// addr 为本继电器的 SubDeviceID 时等同于 SetOutputsContext；否则不更新本继电器的输出状态，
+// 中间未指定的路数不补齐。默认协议的命令不带从站地址，返回 ErrNotModbus
+func (r *Relay) SetOutputsAt(ctx context.Context, addr uint16, states OutputStates) error {
+	if addr == r.SubDeviceID {
+		return r.SetOutputsContext(ctx, states)
+	}
+	if r.framing == FramingVendor {
+		return errors.Wrap(ErrNotModbus, "set outputs failed")
+	}
+	values := map[uint8]uint8{}
+	for _, state := range states {
+		if err := checkOutput(state.Route, state.Value); err != nil {
+			return errors.Wrap(err, "set outputs failed")
+		}
+		values[state.Route] = state.Value
+	}
+	if err := r.writeCoils(ctx, addr, values, nil); err != nil {
+		return errors.Wrap(err, "set outputs failed")
+	}
+	if r.verifyTimeout > 0 {
+		if err := r.confirmCoils(ctx, addr, values); err != nil {
+			return errors.Wrap(err, "set outputs failed")
+		}
+	}
+	return nil
+}
+
+// 逐路写单个线圈
+func (r *Relay) writeCoilsOneByOne(ctx context.Context, addr uint16, values map[uint8]uint8) error {
+	for _, route := range sortedRoutes(values) {
+		if err := r.callTimeout(ctx, WriteCoilFrame(addr, uint16(route-1), values[route] == 1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 发送请求并等待响应，ctx 没有更早的截止时间时超时 1 秒
+func (r *Relay) callTimeout(ctx context.Context, req []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, modbusResponseTimeout)
+	defer cancel()
+	_, err := r.call(ctx, req)
+	return err
+}
+
+// 将要设置的路数拆分为连续的段，段内未指定的路数取 current 中的状态，状态未知时断开为两段
+func coilRuns(values map[uint8]uint8, current OutputStates) []coilRun {
+	routes := sortedRoutes(values)
+	if len(routes) == 0 {
+		return nil
+	}
+	var runs []coilRun
+	var run *coilRun
+	for route := routes[0]; route <= routes[len(routes)-1]; route++ {
+		value, ok := values[route]
+		if !ok {
+			var state OutputState
+			state, ok = current.ByRoute(route)
+			value = state.Value
+		}
+		if !ok {
+			run = nil
+			continue
+		}
+		if run == nil {
+			runs = append(runs, coilRun{start: route})
+			run = &runs[len(runs)-1]
+		}
+		run.values = append(run.values, value == 1)
+	}
+	return runs
+}
+
+// 按路数排序
+func sortedRoutes(values map[uint8]uint8) []uint8 {
+	routes := make([]uint8, 0, len(values))
+	for route := range values {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i] < routes[j] })
+	return routes
+}
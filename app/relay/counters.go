@@ -0,0 +1,150 @@
+package relay
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Counter 一路脉冲计数
+type Counter struct {
+	Route   uint8  `json:"route"`
+	Count   uint32 `json:"count"`   // 累计计数
+	Delta   uint32 `json:"delta"`   // 与上一次读取的差值，第一次读取为 0
+	Wrapped bool   `json:"wrapped"` // 两次读取之间计数越过 32 位上限回绕
+}
+
+// Counters 各路脉冲计数，按路数排序
+type Counters []Counter
+
+// CounterRegisters 计数输入配置：共 routes 路，第 N 路的 32 位计数位于从 base+2(N-1) 开始的两个保持寄存器，
+// 按 WordOrder 解析。上线后按 KeepAlive 间隔读取（被动模式不读取），
+// 可将 COUNTERS 传给 Online 或 AutoPostProperty 定时发送。
+// 寄存器地址因设备而异，需查阅设备手册；需要 Modbus 分帧，未配置时 ReadCounters 返回 ErrNotSupported
+func CounterRegisters(base uint16, routes uint8) Option {
+	return func(r *Relay) {
+		r.counterRegs = &counterRegisters{base: base, routes: routes}
+		r.getters[COUNTERS] = r.GetCounters
+	}
+}
+
+// 计数寄存器
+type counterRegisters struct {
+	base   uint16
+	routes uint8
+}
+
+// 单次读取的计数路数上限
+const maxReadCounters = maxReadRegisters / 2
+
+// CounterDelta 计算两次读取之间的计数增量，cur 小于 prev 时按 32 位回绕计算并返回 wrapped。
+// 设备重启或清零后计数变小也会被当作回绕，需要区分时应结合设备状态判断
+func CounterDelta(prev, cur uint32) (delta uint32, wrapped bool) {
+	return cur - prev, cur < prev
+}
+
+// ReadCounters 读取 routes 的累计计数（功能码 03），routes 为空时读取全部，需要配置 CounterRegisters。
+// 读取结果计入 CounterState，增量相对于上一次读取计算；与其他属性一样发送 EventPropertyUpdate、Data 并更新 LastSeen
+func (r *Relay) ReadCounters(ctx context.Context, routes ...uint8) (map[uint8]uint32, error) {
+	if r.counterRegs == nil {
+		return nil, errors.Wrap(ErrNotSupported, "read counters failed")
+	}
+	if len(routes) == 0 {
+		for route := 1; route <= int(r.counterRegs.routes); route++ {
+			routes = append(routes, uint8(route))
+		}
+	}
+	sorted := append([]uint8{}, routes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, route := range sorted {
+		if route == 0 || route > r.counterRegs.routes {
+			return nil, errors.Errorf("read counters failed: route %d out of range [1, %d]", route, r.counterRegs.routes)
+		}
+	}
+	counts := make(map[uint8]uint32, len(sorted))
+	for i := 0; i < len(sorted); {
+		first, j := sorted[i], i
+		for j < len(sorted) && int(sorted[j]-first) < maxReadCounters {
+			j++
+		}
+		last := sorted[j-1]
+		regs, err := r.readCounterRegisters(ctx, first, last)
+		if err != nil {
+			return nil, errors.Wrap(err, "read counters failed")
+		}
+		for _, route := range sorted[i:j] {
+			k := 2 * int(route-first)
+			counts[route] = DecodeUint32([2]uint16{regs[k], regs[k+1]}, r.wordOrder)
+		}
+		i = j
+	}
+	counters := make(Counters, 0, len(counts))
+	for route, count := range counts {
+		counters = append(counters, Counter{Route: route, Count: count})
+	}
+	r.commit(Data{PropertyType: COUNTERS, Data: counters})
+	return counts, nil
+}
+
+// 读取 first 到 last 路的计数寄存器
+func (r *Relay) readCounterRegisters(ctx context.Context, first, last uint8) ([]uint16, error) {
+	count := 2 * (uint16(last-first) + 1)
+	resp, err := r.call(ctx, ReadHoldingRegistersFrame(r.SubDeviceID, r.counterRegs.base+2*uint16(first-1), count))
+	if err != nil {
+		return nil, err
+	}
+	if int(resp[2]) != 2*int(count) {
+		return nil, errors.Errorf("unexpected byte count %d", resp[2])
+	}
+	return r.decodeRegisters(resp[3 : 3+resp[2]]), nil
+}
+
+// InquiryCounters 读取 CounterRegisters 配置的全部计数，超时 1 秒
+func (r *Relay) InquiryCounters() error {
+	ctx, cancel := context.WithTimeout(r.ctx, modbusResponseTimeout)
+	defer cancel()
+	_, err := r.ReadCounters(ctx)
+	return err
+}
+
+// CounterState 获取最近一次读取的计数
+func (r *Relay) CounterState() Counters {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(Counters(nil), r.counts...)
+}
+
+// GetCounters 获取计数，尚未读取或被中间件丢弃时返回 nil
+func (r *Relay) GetCounters() Property {
+	counters := r.CounterState()
+	if len(counters) == 0 {
+		return nil
+	}
+	return r.getData(r.current(COUNTERS, counters))
+}
+
+// 合并本次读取的计数并计算增量，未读取的路保持原值，按路数排序，需持有 mu
+func (r *Relay) mergeCounters(read Counters) Counters {
+	prev := make(map[uint8]uint32, len(r.counts))
+	current := make(map[uint8]bool, len(read))
+	for _, c := range read {
+		current[c.Route] = true
+	}
+	next := make(Counters, 0, len(r.counts)+len(read))
+	for _, c := range r.counts {
+		prev[c.Route] = c.Count
+		if !current[c.Route] {
+			next = append(next, c)
+		}
+	}
+	for _, c := range read {
+		c.Delta, c.Wrapped = 0, false
+		if p, ok := prev[c.Route]; ok {
+			c.Delta, c.Wrapped = CounterDelta(p, c.Count)
+		}
+		next = append(next, c)
+	}
+	sort.Slice(next, func(i, j int) bool { return next[i].Route < next[j].Route })
+	return next
+}
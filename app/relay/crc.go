@@ -0,0 +1,61 @@
+package relay
+
+// 帧头与帧尾
+const (
+	frameHead byte = 0xA0
+	frameTail byte = 0xA7
+)
+
+// CRC16 计算 CRC-16/Modbus 校验值
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// AppendCRC16 在帧尾追加 CRC-16/Modbus 校验值，低字节在前
+func AppendCRC16(frame []byte) []byte {
+	crc := CRC16(frame)
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+// CRCCheck 对接收帧做 CRC-16/Modbus 校验，适用于帧尾两字节为 CRC 的设备，其他算法使用 WithChecksum
+func CRCCheck() Option {
+	return func(r *Relay) {
+		r.verifyFrame = verifyCRC16
+	}
+}
+
+// 校验帧头帧尾，默认协议没有校验位
+func verifyEnvelope(frame []byte) error {
+	if len(frame) < 4 {
+		return protocolErrorf(ErrShortFrame, "frame too short: % X", frame)
+	}
+	if frame[0] != frameHead || frame[len(frame)-1] != frameTail {
+		return protocolErrorf(ErrBadFrame, "bad frame envelope: % X", frame)
+	}
+	return nil
+}
+
+// 校验帧尾 CRC-16/Modbus
+func verifyCRC16(frame []byte) error {
+	if len(frame) < 4 {
+		return protocolErrorf(ErrShortFrame, "frame too short: % X", frame)
+	}
+	n := len(frame) - 2
+	want := CRC16(frame[:n])
+	got := uint16(frame[n]) | uint16(frame[n+1])<<8
+	if want != got {
+		return protocolErrorf(ErrCRCMismatch, "crc mismatch: want %04X, got %04X", want, got)
+	}
+	return nil
+}
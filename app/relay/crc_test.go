@@ -0,0 +1,41 @@
+package relay
+
+import (
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	if got := CRC16([]byte("123456789")); got != 0x4B37 {
+		t.Fatalf("CRC16 = %04X, want 4B37", got)
+	}
+	frame := AppendCRC16([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A})
+	if frame[6] != 0xC5 || frame[7] != 0xCD {
+		t.Fatalf("AppendCRC16 = % X", frame)
+	}
+}
+
+func TestVerifyCRC16(t *testing.T) {
+	good := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}
+	if err := verifyCRC16(good); err != nil {
+		t.Fatal(err)
+	}
+	bad := append([]byte{}, good...)
+	bad[3] ^= 0x01
+	if err := verifyCRC16(bad); err == nil {
+		t.Fatal("corrupted frame passed crc check")
+	}
+	if err := verifyCRC16(good[:3]); err == nil {
+		t.Fatal("short frame passed crc check")
+	}
+}
+
+func TestVerifyEnvelope(t *testing.T) {
+	frame, _ := commandFormatter(testFrames[0])
+	if err := verifyEnvelope(frame); err != nil {
+		t.Fatal(err)
+	}
+	frame[12] = 0x00
+	if err := verifyEnvelope(frame); err == nil {
+		t.Fatal("bad frame passed envelope check")
+	}
+}
@@ -0,0 +1,115 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// Debounce 输入去抖配置，输入变化后保持 d 不变才接受新值，期间变回原值则取消。
+// 去抖在保存输入状态之前进行，OnInputEdge、属性变化回调和上报看到的都是去抖后的状态；
+// 上线后的第一次读取直接接受。
+func Debounce(d time.Duration) Option {
+	return func(r *Relay) {
+		r.debounce.d = d
+	}
+}
+
+// 输入去抖，mu 在整个保存过程中持有，保证定时提交与读取循环顺序一致
+type debouncer struct {
+	mu      sync.Mutex
+	d       time.Duration
+	pending map[uint8]*pendingInput
+}
+
+// 等待稳定的输入值，cancel 关闭时放弃提交
+type pendingInput struct {
+	value  uint8
+	cancel chan struct{}
+	timer  Timer
+}
+
+// 放弃提交并停止定时器
+func (p *pendingInput) stop() {
+	p.timer.Stop()
+	close(p.cancel)
+}
+
+// 返回本次应保存的输入状态，变化中的路数保持原值，稳定后由定时器提交。需持有 debounce.mu
+func (r *Relay) debounceInputs(states InputStates) InputStates {
+	r.mu.RLock()
+	seen := r.seen[INPUTSTATE]
+	committed := make(map[uint8]uint8, len(r.inputState))
+	for _, state := range r.inputState {
+		committed[state.Route] = state.Value
+	}
+	r.mu.RUnlock()
+	if !seen {
+		return states
+	}
+	db := &r.debounce
+	if db.pending == nil {
+		db.pending = make(map[uint8]*pendingInput)
+	}
+	out := append(InputStates{}, states...)
+	for i, state := range out {
+		value, ok := committed[state.Route]
+		if !ok {
+			continue
+		}
+		p := db.pending[state.Route]
+		if state.Value == value {
+			if p != nil {
+				p.stop()
+				delete(db.pending, state.Route)
+			}
+			continue
+		}
+		out[i].Value = value
+		if p != nil && p.value == state.Value {
+			continue
+		}
+		if p != nil {
+			p.stop()
+		}
+		p = &pendingInput{value: state.Value, cancel: make(chan struct{}), timer: r.clock.NewTimer(db.d)}
+		db.pending[state.Route] = p
+		go r.awaitStable(state.Route, p)
+	}
+	return out
+}
+
+// 等待去抖窗口结束，期间被取消或下线时不提交
+func (r *Relay) awaitStable(route uint8, p *pendingInput) {
+	select {
+	case <-p.timer.C():
+		r.commitInput(route, p)
+	case <-p.cancel:
+	case <-r.closed:
+		p.timer.Stop()
+	}
+}
+
+// 去抖窗口结束，提交稳定后的输入值
+func (r *Relay) commitInput(route uint8, p *pendingInput) {
+	db := &r.debounce
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.pending[route] != p {
+		return
+	}
+	value := p.value
+	delete(db.pending, route)
+	if isClosed(r.closed) {
+		return
+	}
+	r.mu.RLock()
+	next := append(InputStates{}, r.inputState...)
+	raw := append([]byte{}, r.raw[INPUTSTATE]...)
+	r.mu.RUnlock()
+	for i := range next {
+		if next[i].Route == route {
+			next[i].Value = value
+		}
+	}
+	r.commit(Data{PropertyType: INPUTSTATE, Data: next, Raw: raw})
+}
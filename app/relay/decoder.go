@@ -0,0 +1,48 @@
+package relay
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Decoder 帧解码器，将一帧已校验的数据解码为属性数据。
+// 返回的 Data.Data 需为对应属性类型的值：OUTPUTSTATE 为 OutputStates，
+// INPUTSTATE 为 InputStates，TH 为 TemperatureAndHumidity。
+type Decoder interface {
+	Decode(frame []byte) (Data, error)
+}
+
+// DecoderFunc 函数形式的解码器
+type DecoderFunc func(frame []byte) (Data, error)
+
+// Decode 解码
+func (fn DecoderFunc) Decode(frame []byte) (Data, error) {
+	return fn(frame)
+}
+
+// WithDecoder 解码器配置，用于帧格式不同的继电器型号，默认使用 DefaultDecoder
+func WithDecoder(d Decoder) Option {
+	return func(r *Relay) {
+		r.decoder = d
+	}
+}
+
+// DefaultDecoder 默认解码器，第 4 字节为命令：AA 输出状态、1B 输入状态、2A 温湿度
+var DefaultDecoder Decoder = DecoderFunc(decodeFrame)
+
+func decodeFrame(frame []byte) (Data, error) {
+	if len(frame) < minFrameLength {
+		return Data{}, errors.Wrap(protocolErrorf(ErrShortFrame, "frame too short: % X", frame), "decode frame failed")
+	}
+	switch frame[3] {
+	case 0xAA:
+		states, err := decodeOutputState(frame)
+		return Data{PropertyType: OUTPUTSTATE, Data: states}, err
+	case 0x1B:
+		states, err := decodeInputState(frame)
+		return Data{PropertyType: INPUTSTATE, Data: states}, err
+	case 0x2A:
+		th, err := decodeTH(frame)
+		return Data{PropertyType: TH, Data: th}, err
+	}
+	return Data{}, errors.Wrap(protocolErrorf(ErrUnknownFunction, "unknown command %02X", frame[3]), "decode frame failed")
+}
@@ -0,0 +1,36 @@
+package relay
+
+import (
+	"testing"
+)
+
+func TestWithDecoder(t *testing.T) {
+	decoder := DecoderFunc(func(frame []byte) (Data, error) {
+		return Data{PropertyType: INPUTSTATE, Data: InputStates{{Route: 1, Value: frame[1]}}}, nil
+	})
+	r := New(nil, nil, 0x1001, WithDecoder(decoder))
+	frame, _ := commandFormatter(testFrames[0])
+	r.handleFrame(frame)
+	states := r.InputState()
+	if len(states) != 1 || states[0].Value != 0x10 {
+		t.Fatalf("input state = %v", states)
+	}
+	if raw := r.rawFrame(INPUTSTATE); len(raw) != 13 {
+		t.Fatalf("raw frame = % X", raw)
+	}
+}
+
+func TestDefaultDecoder(t *testing.T) {
+	frame, _ := commandFormatter(testFrames[2])
+	data, err := DefaultDecoder.Decode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th := data.Data.(TemperatureAndHumidity); data.PropertyType != TH || th.Temperature != 25.5 || th.Humidity != 60.2 {
+		t.Fatalf("decoded %+v", data)
+	}
+	frame[3] = 0x00
+	if _, err := DefaultDecoder.Decode(frame); err == nil {
+		t.Fatal("unknown command decoded")
+	}
+}
@@ -0,0 +1,38 @@
+package relay
+
+import (
+	"crypto/tls"
+	"iot-sdk-go/sdk/device"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Dial 建立到 addr 的 TCP 连接，cfg 不为 nil 时完成 TLS 握手后返回；
+// cfg 为 nil 时为明文连接。timeout 同时限制建连和握手时间，0 表示不超时。
+func Dial(addr string, cfg *tls.Config, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if cfg == nil {
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, errors.Wrap(err, "dial failed")
+		}
+		return conn, nil
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial tls failed")
+	}
+	return conn, nil
+}
+
+// NewTLS 通过 Dial 建立连接并创建继电器实例，cfg 为 nil 时为明文连接。
+// 需要断线重连时可配合 Reconnect(func() (net.Conn, error) { return Dial(addr, cfg, timeout) }, ...) 使用。
+func NewTLS(DeviceInstance *device.Device, addr string, cfg *tls.Config, timeout time.Duration, subDeviceID uint16, options ...Option) (*Relay, error) {
+	conn, err := Dial(addr, cfg, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return New(DeviceInstance, conn, subDeviceID, options...), nil
+}
@@ -0,0 +1,58 @@
+package relay
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// 解码与协议错误，返回的错误包装了这些错误，可用 errors.Is 判断。
+// 可重试（IsRetryable 为 true）：ErrCRCMismatch、ErrShortFrame、ErrBadFrame，通常是线路干扰或错位，丢弃该帧继续读取即可；
+// 不可重试：ErrNoValidFrame、ErrUnknownFunction 多为波特率、分帧或协议配置错误，ErrRelayClosed 需要重新创建继电器
+var (
+	// ErrCRCMismatch 校验失败
+	ErrCRCMismatch = errors.New("crc mismatch")
+	// ErrShortFrame 帧长度不足
+	ErrShortFrame = errors.New("frame too short")
+	// ErrBadFrame 帧头帧尾、MBAP 头或响应内容不合法
+	ErrBadFrame = errors.New("bad frame")
+	// ErrUnknownFunction 未知的命令码或 Modbus 功能码
+	ErrUnknownFunction = errors.New("unknown function")
+	// ErrNoValidFrame 连续丢弃大量字节仍未找到有效帧
+	ErrNoValidFrame = errors.New("no valid frame")
+	// ErrNotConnected 没有连接
+	ErrNotConnected = errors.New("not connected")
+	// ErrRelayClosed 继电器已下线，不能再次初始化或上线
+	ErrRelayClosed = errors.New("relay closed")
+)
+
+// 带分类的协议错误，消息保持原样，Unwrap 返回分类
+type protocolError struct {
+	kind error
+	msg  string
+}
+
+func (e *protocolError) Error() string {
+	return e.msg
+}
+
+func (e *protocolError) Unwrap() error {
+	return e.kind
+}
+
+// 创建 kind 分类的协议错误
+func protocolErrorf(kind error, format string, args ...interface{}) error {
+	return errors.WithStack(&protocolError{kind: kind, msg: fmt.Sprintf(format, args...)})
+}
+
+// IsRetryable 是否为可重试的错误：单帧的校验、长度、格式错误，读写超时，以及写入未确认
+func IsRetryable(err error) bool {
+	for _, target := range []error{ErrCRCMismatch, ErrShortFrame, ErrBadFrame, ErrNotConfirmed} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,50 @@
+package relay
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTypedErrors(t *testing.T) {
+	frame := AppendCRC16([]byte{0x01, 0x03, 0x02, 0x12, 0x34})
+	frame[3] ^= 0xFF
+	cases := []struct {
+		name      string
+		err       error
+		target    error
+		retryable bool
+	}{
+		{"crc", verifyCRC16(frame), ErrCRCMismatch, true},
+		{"short", verifyEnvelope([]byte{0xA0}), ErrShortFrame, true},
+		{"envelope", verifyEnvelope(bytes.Repeat([]byte{0x00}, 13)), ErrBadFrame, true},
+		{"unknown command", func() error {
+			_, err := decodeFrame(testStream(t, "A0 10 01 FF 00 00 00 00 00 00 00 00 A7"))
+			return err
+		}(), ErrUnknownFunction, false},
+		{"wrapped closed", errors.Wrap(ErrRelayClosed, "online failed"), ErrRelayClosed, false},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.target) {
+			t.Errorf("%s: %v is not %v", c.name, c.err, c.target)
+		}
+		if IsRetryable(c.err) != c.retryable {
+			t.Errorf("%s: retryable %v, want %v", c.name, !c.retryable, c.retryable)
+		}
+	}
+	reader := newFrameReader(13, verifyEnvelope)
+	src := &chunkReader{data: bytes.Repeat([]byte{0xFF}, maxResyncBytes+13), sizes: []int{13}}
+	for {
+		_, skipped, err := reader.next(src)
+		if skipped > 0 {
+			if !errors.Is(err, ErrNoValidFrame) || IsRetryable(err) {
+				t.Fatalf("resync limit error %v", err)
+			}
+			break
+		}
+		if err == nil {
+			t.Fatal("frame read from garbage")
+		}
+	}
+}
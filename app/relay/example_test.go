@@ -0,0 +1,23 @@
+package relay
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// 温度取整后再上报
+func ExampleMiddleware() {
+	round := func(r *Relay, data Data) (Data, bool) {
+		if th, ok := data.Data.(TemperatureAndHumidity); ok {
+			th.Temperature = math.Round(th.Temperature)
+			data.Data = th
+		}
+		return data, true
+	}
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second), Middlewares(round))
+	frame, _ := commandFormatter("A0 10 01 2A 01 19 05 3C 02 00 00 00 A7")
+	r.SaveTH(frame)
+	fmt.Println(r.GetTH())
+	// Output: {26 60.2}
+}
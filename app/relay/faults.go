@@ -0,0 +1,106 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Faults 故障寄存器的值，每一位为一种故障。各位的含义由设备决定，常量为常见排列，需对照设备手册
+type Faults uint16
+
+// 常见的故障位
+const (
+	// FaultOverTemperature 过温
+	FaultOverTemperature Faults = 1 << iota
+	// FaultOverCurrent 过流
+	FaultOverCurrent
+	// FaultOverVoltage 过压
+	FaultOverVoltage
+	// FaultUnderVoltage 欠压
+	FaultUnderVoltage
+	// FaultShortCircuit 短路
+	FaultShortCircuit
+)
+
+// 故障位名称
+var faultNames = []struct {
+	bit  Faults
+	name string
+}{
+	{FaultOverTemperature, "over-temperature"},
+	{FaultOverCurrent, "over-current"},
+	{FaultOverVoltage, "over-voltage"},
+	{FaultUnderVoltage, "under-voltage"},
+	{FaultShortCircuit, "short-circuit"},
+}
+
+// Has 是否包含 bit 中的全部故障
+func (f Faults) Has(bit Faults) bool {
+	return f&bit == bit
+}
+
+// Names 当前故障的名称，未命名的位为 bit N
+func (f Faults) Names() []string {
+	var names []string
+	rest := f
+	for _, n := range faultNames {
+		if f.Has(n.bit) {
+			names = append(names, n.name)
+			rest &^= n.bit
+		}
+	}
+	for i := uint(0); i < 16; i++ {
+		if rest&(1<<i) != 0 {
+			names = append(names, fmt.Sprintf("bit %d", i))
+		}
+	}
+	return names
+}
+
+func (f Faults) String() string {
+	if f == 0 {
+		return "none"
+	}
+	return strings.Join(f.Names(), "|")
+}
+
+// FaultRegisters 故障寄存器配置：status 为故障状态保持寄存器，向 clear 写入 clearValue 清除故障。
+// 寄存器地址与清除方式因设备而异，需查阅设备手册，未配置时 ReadFaults、ClearFaults 返回 ErrNotSupported
+func FaultRegisters(status, clear, clearValue uint16) Option {
+	return func(r *Relay) {
+		r.faults = &faultRegisters{status: status, clear: clear, clearValue: clearValue}
+	}
+}
+
+// 故障寄存器地址
+type faultRegisters struct {
+	status     uint16
+	clear      uint16
+	clearValue uint16
+}
+
+// ReadFaults 读取故障寄存器（Modbus 功能码 03），需要配置 FaultRegisters 与 Modbus 分帧
+func (r *Relay) ReadFaults(ctx context.Context) (Faults, error) {
+	if r.faults == nil {
+		return 0, errors.Wrap(ErrNotSupported, "read faults failed")
+	}
+	v, err := r.ReadRegisterAt(ctx, r.SubDeviceID, r.faults.status)
+	if err != nil {
+		return 0, errors.Wrap(err, "read faults failed")
+	}
+	return Faults(v), nil
+}
+
+// ClearFaults 清除故障（Modbus 功能码 06），需要配置 FaultRegisters 与 Modbus 分帧
+func (r *Relay) ClearFaults(ctx context.Context) error {
+	if r.faults == nil {
+		return errors.Wrap(ErrNotSupported, "clear faults failed")
+	}
+	if err := r.SetRegisterAt(ctx, r.SubDeviceID, r.faults.clear, r.faults.clearValue); err != nil {
+		return errors.Wrap(err, "clear faults failed")
+	}
+	return nil
+}
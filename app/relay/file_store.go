@@ -0,0 +1,70 @@
+package relay
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// FileStore 文件状态存储，每个设备最近的快照以 JSON 保存在 dir 下的 <id>.json，
+// 先写临时文件再重命名，进程崩溃时不会留下写了一半的快照
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 创建文件状态存储，dir 不存在时创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "create file store failed")
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save 保存快照
+func (s *FileStore) Save(id uint16, snap Snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return errors.Wrap(err, "save snapshot failed")
+	}
+	tmp, err := ioutil.TempFile(s.dir, ".snapshot-*")
+	if err != nil {
+		return errors.Wrap(err, "save snapshot failed")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "save snapshot failed")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "save snapshot failed")
+	}
+	if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+		return errors.Wrap(err, "save snapshot failed")
+	}
+	return nil
+}
+
+// Load 读取设备最近的快照，没有保存过时返回 false
+func (s *FileStore) Load(id uint16) (Snapshot, bool, error) {
+	b, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, errors.Wrap(err, "load snapshot failed")
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return Snapshot{}, false, errors.Wrap(err, "load snapshot failed")
+	}
+	return snap, true, nil
+}
+
+// 设备快照文件路径
+func (s *FileStore) path(id uint16) string {
+	return filepath.Join(s.dir, strconv.Itoa(int(id))+".json")
+}
@@ -0,0 +1,93 @@
+package relay
+
+import (
+	"io"
+)
+
+// 重新对齐时最多丢弃的字节数，超过后清空缓冲并报告错误，通常是设备或端口配置错误
+const maxResyncBytes = 4096
+
+// 帧读取器，从连接中累积数据并按帧长切分出有效帧。
+// 校验失败时逐字节向后滑动查找下一个有效帧，丢失或多出字节后可自动恢复对齐；
+// 连续 maxResyncBytes 字节都找不到有效帧时清空缓冲，重新开始查找。
+type frameReader struct {
+	size      func(head []byte) (int, error) // 根据已读到的数据计算帧长，数据不足以判断时返回需要的字节数
+	verify    func(frame []byte) error
+	buf       []byte
+	tmp       []byte
+	resyncing bool // 正在查找下一个有效帧
+	skipped   int  // 本次查找已丢弃的字节数
+}
+
+// 帧校验失败，读取器随后开始逐字节查找下一个有效帧
+type frameError struct {
+	err error
+}
+
+func (e *frameError) Error() string {
+	return e.err.Error()
+}
+
+func (e *frameError) Unwrap() error {
+	return e.err
+}
+
+// 定长帧读取器
+func newFrameReader(length int, verify func(frame []byte) error) *frameReader {
+	return newSizedReader(length, func([]byte) (int, error) {
+		return length, nil
+	}, verify)
+}
+
+// 变长帧读取器，bufSize 为单次读取的缓冲大小
+func newSizedReader(bufSize int, size func(head []byte) (int, error), verify func(frame []byte) error) *frameReader {
+	return &frameReader{
+		size:   size,
+		verify: verify,
+		buf:    make([]byte, 0, 2*bufSize),
+		tmp:    make([]byte, bufSize),
+	}
+}
+
+// 读取下一个有效帧，skipped 为重新对齐时丢弃的字节数。
+// 开始错位或丢弃字节超过 maxResyncBytes 时返回 *frameError，再次调用继续查找。
+func (f *frameReader) next(r io.Reader) (frame []byte, skipped int, err error) {
+	for {
+		length, verr := f.size(f.buf)
+		if verr == nil && len(f.buf) < length {
+			n, err := r.Read(f.tmp)
+			f.buf = append(f.buf, f.tmp[:n]...)
+			if err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		if verr == nil {
+			verr = f.verify(f.buf[:length])
+		}
+		if verr == nil {
+			frame = append([]byte{}, f.buf[:length]...)
+			f.buf = append(f.buf[:0], f.buf[length:]...)
+			skipped = f.skipped
+			f.resyncing, f.skipped = false, 0
+			return frame, skipped, nil
+		}
+		f.buf = append(f.buf[:0], f.buf[1:]...)
+		f.skipped++
+		if f.skipped >= maxResyncBytes {
+			skipped = f.skipped + len(f.buf)
+			f.reset()
+			return nil, skipped, &frameError{protocolErrorf(ErrNoValidFrame, "no valid frame in %d bytes, check device and port settings", skipped)}
+		}
+		if !f.resyncing {
+			f.resyncing = true
+			return nil, 0, &frameError{verr}
+		}
+	}
+}
+
+// 丢弃已缓冲的不完整数据
+func (f *frameReader) reset() {
+	f.buf = f.buf[:0]
+	f.resyncing, f.skipped = false, 0
+}
@@ -0,0 +1,177 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func testStream(t *testing.T, frames ...string) []byte {
+	var stream []byte
+	for _, f := range frames {
+		b, err := commandFormatter(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, b...)
+	}
+	return stream
+}
+
+func TestFrameReaderResync(t *testing.T) {
+	stream := testStream(t, testFrames[0], "00", testFrames[1], testFrames[2])
+	reader := newFrameReader(13, verifyEnvelope)
+	src := bytes.NewReader(stream)
+	wantSkipped := []int{0, 1, 0}
+	for i, want := range testFrames {
+		frame, skipped, err := reader.next(src)
+		if _, ok := err.(*frameError); ok && i == 1 {
+			frame, skipped, err = reader.next(src)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantFrame := testStream(t, want)
+		if !bytes.Equal(frame, wantFrame) || skipped != wantSkipped[i] {
+			t.Fatalf("frame %d = % X skipped %d, want % X skipped %d", i, frame, skipped, wantFrame, wantSkipped[i])
+		}
+	}
+}
+
+func TestLengthPrefixReader(t *testing.T) {
+	frames := []string{
+		"A0 01 0A 1B 0F 00 00 00 00 A7",
+		"A0 01 0F AA 00 55 00 00 00 00 00 00 00 00 A7",
+	}
+	r := New(nil, nil, 1, LengthPrefix(2, 1, 0), FrameLength(32))
+	reader := r.newReader(32)
+	src := &chunkReader{data: testStream(t, frames[0], "00", "A0 01 40", frames[1]), sizes: []int{3, 7}}
+	var got [][]byte
+	for len(got) < len(frames) {
+		frame, _, err := reader.next(src)
+		if _, ok := err.(*frameError); ok {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, frame)
+	}
+	for i, want := range frames {
+		if !bytes.Equal(got[i], testStream(t, want)) {
+			t.Fatalf("frame %d = % X, want %s", i, got[i], want)
+		}
+	}
+	data, err := DefaultDecoder.Decode(got[1])
+	if err != nil || data.PropertyType != OUTPUTSTATE {
+		t.Fatalf("decode = %+v, %v", data, err)
+	}
+
+	bad := New(nil, &net.TCPConn{}, 1, LengthPrefix(0, 3, 0))
+	if err := bad.ReadLoop(DefaultFrameLength); err == nil {
+		t.Fatal("expected bad length prefix error")
+	}
+}
+
+// 按 sizes 循环切分数据的读取器，每次 Read 最多返回一段
+type chunkReader struct {
+	data  []byte
+	sizes []int
+	n     int
+}
+
+func (c *chunkReader) Read(b []byte) (int, error) {
+	if len(c.data) == 0 {
+		return 0, io.EOF
+	}
+	size := c.sizes[c.n%len(c.sizes)]
+	c.n++
+	if size > len(c.data) {
+		size = len(c.data)
+	}
+	if size > len(b) {
+		size = len(b)
+	}
+	n := copy(b, c.data[:size])
+	c.data = c.data[n:]
+	return n, nil
+}
+
+func TestFrameReaderFragmented(t *testing.T) {
+	stream := testStream(t, append(append([]string{}, testFrames...), testFrames...)...)
+	for _, sizes := range [][]int{{1}, {2, 5}, {19, 7}, {13}, {20, 6, 1, 12}} {
+		reader := newFrameReader(13, verifyEnvelope)
+		src := &chunkReader{data: append([]byte{}, stream...), sizes: sizes}
+		for i := 0; i < 2*len(testFrames); i++ {
+			frame, skipped, err := reader.next(src)
+			if err != nil {
+				t.Fatalf("sizes %v frame %d: %v", sizes, i, err)
+			}
+			want := testStream(t, testFrames[i%len(testFrames)])
+			if !bytes.Equal(frame, want) || skipped != 0 {
+				t.Fatalf("sizes %v frame %d = % X skipped %d, want % X", sizes, i, frame, skipped, want)
+			}
+		}
+		if _, _, err := reader.next(src); err != io.EOF {
+			t.Fatalf("sizes %v: trailing read %v, want EOF", sizes, err)
+		}
+	}
+}
+
+func TestReadLoopFragmented(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001)
+	if err := r.ReadLoop(DefaultFrameLength); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	stream := testStream(t, testFrames[1], testFrames[2])
+	// 一帧半，然后是剩余的半帧
+	for _, part := range [][]byte{stream[:19], stream[19:]} {
+		if _, err := client.Write(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for r.Stats().FramesRead < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("frames read %d, want 2", r.Stats().FramesRead)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if th := r.TH(); th.Temperature != 25.5 || th.Humidity != 60.2 {
+		t.Fatalf("TH = %+v", th)
+	}
+}
+
+func TestFrameReaderGarbage(t *testing.T) {
+	stream := append(bytes.Repeat([]byte{0xFF}, 2*maxResyncBytes+maxResyncBytes/2), testStream(t, testFrames[0])...)
+	reader := newFrameReader(13, verifyEnvelope)
+	src := &chunkReader{data: stream, sizes: []int{13}}
+	limits := 0
+	for {
+		frame, skipped, err := reader.next(src)
+		if _, ok := err.(*frameError); ok {
+			if skipped > 0 {
+				limits++
+			}
+			if len(reader.buf) > maxResyncBytes {
+				t.Fatalf("buffer grew to %d bytes", len(reader.buf))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(frame, testStream(t, testFrames[0])) {
+			t.Fatalf("frame % X after garbage", frame)
+		}
+		break
+	}
+	if limits != 2 {
+		t.Fatalf("resync limit reported %d times, want 2", limits)
+	}
+}
@@ -0,0 +1,120 @@
+package relay
+
+import (
+	"encoding/binary"
+)
+
+// FramingMode 分帧方式
+type FramingMode int
+
+const (
+	// FramingVendor 默认协议：A0 开头 A7 结尾的定长帧，长度由 FrameLength 配置
+	FramingVendor FramingMode = iota
+	// FramingRTU Modbus RTU：按功能码计算帧长，CRC-16 校验
+	FramingRTU
+	// FramingTCP Modbus TCP：7 字节 MBAP 头（事务号、协议号、长度、单元号），按长度字段分帧，没有 CRC
+	FramingTCP
+)
+
+// Framing 分帧方式配置，默认 FramingVendor。
+// FramingRTU、FramingTCP 下读取循环只处理 Modbus 响应，不再发送默认协议的询问命令；
+// 两种 Modbus 分帧使用相同的请求方法（ReadRegister 等），FramingTCP 下按 MBAP 事务号匹配响应。
+func Framing(mode FramingMode) Option {
+	return func(r *Relay) {
+		r.framing = mode
+	}
+}
+
+// RTU 单次读取缓冲大小，不小于最长的 RTU 帧
+const rtuBufSize = 256
+
+// 按分帧方式创建帧读取器
+func (r *Relay) newReader(length int) *frameReader {
+	switch r.framing {
+	case FramingRTU:
+		return newSizedReader(rtuBufSize, rtuFrameSize, verifyCRC16)
+	case FramingTCP:
+		return newSizedReader(mbapHeaderSize+rtuBufSize, mbapFrameSize, verifyMBAP)
+	}
+	if r.lengthPrefix != nil {
+		return newSizedReader(length, r.lengthPrefix.frameSize(length), r.verifyFrame)
+	}
+	return newFrameReader(length, r.verifyFrame)
+}
+
+// 计算 Modbus RTU 响应帧长度：地址、功能码、数据、两字节 CRC
+func rtuFrameSize(head []byte) (int, error) {
+	if len(head) < 2 {
+		return 2, nil
+	}
+	fn := head[1]
+	switch {
+	case fn&0x80 != 0:
+		return 5, nil
+	case fn >= FuncReadCoils && fn <= FuncReadInputRegisters:
+		if len(head) < 3 {
+			return 3, nil
+		}
+		return 5 + int(head[2]), nil
+	case fn == FuncWriteSingleCoil, fn == FuncWriteSingleRegister,
+		fn == FuncWriteMultipleCoils, fn == FuncWriteMultipleRegisters:
+		return 8, nil
+	case fn == FuncReadDeviceID:
+		return deviceIDFrameSize(head), nil
+	}
+	return 0, protocolErrorf(ErrUnknownFunction, "unknown modbus function code %02X", fn)
+}
+
+// 读设备标识响应长度：地址、功能码、MEI、读取类型、一致性等级、后续标志、下一对象、对象数，
+// 之后每个对象为编号、长度、值
+func deviceIDFrameSize(head []byte) int {
+	const fixed = 8
+	if len(head) < fixed {
+		return fixed
+	}
+	pos := fixed
+	for i := 0; i < int(head[fixed-1]); i++ {
+		if len(head) < pos+2 {
+			return pos + 2
+		}
+		pos += 2 + int(head[pos+1])
+	}
+	return pos + 2
+}
+
+// MBAP 头长度：事务号、协议号、长度各两字节，单元号一字节
+const mbapHeaderSize = 7
+
+// 计算 Modbus TCP 帧长度：长度字段之前的 6 字节加长度字段（单元号与 PDU）
+func mbapFrameSize(head []byte) (int, error) {
+	if len(head) < mbapHeaderSize {
+		return mbapHeaderSize, nil
+	}
+	length := int(binary.BigEndian.Uint16(head[4:6]))
+	if length < 2 || length > rtuBufSize {
+		return 0, protocolErrorf(ErrBadFrame, "bad mbap length %d", length)
+	}
+	return 6 + length, nil
+}
+
+// 校验 MBAP 协议号，Modbus 为 0
+func verifyMBAP(frame []byte) error {
+	if binary.BigEndian.Uint16(frame[2:4]) != 0 {
+		return protocolErrorf(ErrBadFrame, "bad mbap protocol id: % X", frame)
+	}
+	return nil
+}
+
+// 将 RTU 请求帧转换为 Modbus TCP 帧：去掉 CRC，加上 MBAP 头
+func mbapFrame(id uint16, rtu []byte) []byte {
+	adu := rtu[:len(rtu)-2]
+	frame := make([]byte, 6, 6+len(adu))
+	binary.BigEndian.PutUint16(frame[0:], id)
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(adu)))
+	return append(frame, adu...)
+}
+
+// 将 Modbus TCP 响应转换为 RTU 帧布局（单元号、PDU、CRC），响应解析与 RTU 共用
+func rtuFromMBAP(frame []byte) (id uint16, rtu []byte) {
+	return binary.BigEndian.Uint16(frame[0:2]), AppendCRC16(append([]byte{}, frame[6:]...))
+}
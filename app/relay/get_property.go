@@ -1,30 +1,131 @@
 package relay
 
-// Data 发送的数据
+import (
+	"time"
+)
+
+// Data 发送的数据，中间件、事件与属性上报使用同一结构。
+// Data 字段的类型由 PropertyType 决定：
+//
+//	OUTPUTSTATE  OutputStates，每路一项，Value 为 1 闭合、0 断开
+//	INPUTSTATE   InputStates，每路一项，Value 为 1 有输入、0 无输入
+//	TH           TemperatureAndHumidity，已按 Calibration 校准
+//	COUNTERS     Counters，按路数排序，包含与上一次读取的增量
 type Data struct {
-	PropertyType PropertyType
-	Data         interface{}
+	PropertyType PropertyType  // 属性类型
+	Data         interface{}   // 解码后的属性值
+	Raw          []byte        // 最近一次收到的原始帧副本，未收到时为 nil
+	Time         time.Time     // 收到该属性的时间，未收到时为零值
+	Latency      time.Duration // 查询往返时间，由 TimestampMiddleware 计算，无法对应到查询时为 0
 }
 
-// 获取数据
+// 获取数据，中间件终止时返回 nil；该帧已由 Data 通道执行过中间件时使用其结果
 func (r *Relay) getData(data Data) interface{} {
-	for _, mw := range r.middlewares {
-		data = mw(r, data)
+	data, ok := r.takeProcessed(data)
+	if !ok {
+		return nil
 	}
 	return data.Data
 }
 
-// GetOutputState 获取输出状态
+// 一帧数据的中间件处理结果
+type processedData struct {
+	at   time.Time // 帧的接收时间
+	data Data
+	ok   bool
+}
+
+// 保存 t 类型一帧数据的中间件处理结果，at 为该帧的接收时间
+func (r *Relay) saveProcessed(t PropertyType, at time.Time, data Data, ok bool) {
+	data.Raw = append([]byte{}, data.Raw...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.processed == nil {
+		r.processed = make(map[PropertyType]processedData)
+	}
+	r.processed[t] = processedData{at: at, data: data, ok: ok}
+}
+
+// 取出同一帧已保存的处理结果，没有时执行中间件
+func (r *Relay) takeProcessed(data Data) (Data, bool) {
+	if !data.Time.IsZero() {
+		r.mu.Lock()
+		p, ok := r.processed[data.PropertyType]
+		if ok && p.at.Equal(data.Time) {
+			delete(r.processed, data.PropertyType)
+			r.mu.Unlock()
+			return p.data, p.ok
+		}
+		r.mu.Unlock()
+	}
+	return r.applyMiddlewares(data)
+}
+
+// 依次执行中间件，终止时返回 false
+func (r *Relay) applyMiddlewares(data Data) (Data, bool) {
+	r.cfgMu.RLock()
+	middlewares := r.middlewares
+	r.cfgMu.RUnlock()
+	for _, mw := range middlewares {
+		var next bool
+		if data, next = mw(r, data); !next {
+			return data, false
+		}
+	}
+	return data, true
+}
+
+// GetOutputState 获取输出状态，被中间件丢弃时返回 nil
 func (r *Relay) GetOutputState() Property {
-	return r.getData(Data{PropertyType: OUTPUTSTATE, Data: r.outputState})
+	return r.getData(r.current(OUTPUTSTATE, r.OutputState()))
 }
 
-// GetInputState 获取 8 路输入状态
+// GetInputState 获取 8 路输入状态，被中间件丢弃时返回 nil
 func (r *Relay) GetInputState() Property {
-	return r.getData(Data{PropertyType: INPUTSTATE, Data: r.inputState})
+	return r.getData(r.current(INPUTSTATE, r.InputState()))
 }
 
-// GetTH 获取温湿度
+// GetTH 获取温湿度，被中间件丢弃时返回 nil
 func (r *Relay) GetTH() Property {
-	return r.getData(Data{PropertyType: TH, Data: r.th})
+	return r.getData(r.current(TH, r.TH()))
+}
+
+// 当前属性值对应的数据，附带最近一次收到的原始帧与时间
+func (r *Relay) current(t PropertyType, value Property) Data {
+	data := Data{PropertyType: t, Data: value, Raw: r.rawFrame(t)}
+	r.mu.RLock()
+	data.Time = r.updated[t]
+	r.mu.RUnlock()
+	return data
+}
+
+// OutputState 当前输出状态的副本，可在其他协程中调用
+func (r *Relay) OutputState() OutputStates {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(OutputStates{}, r.outputState...)
+}
+
+// InputState 当前输入状态的副本，可在其他协程中调用
+func (r *Relay) InputState() InputStates {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(InputStates{}, r.inputState...)
+}
+
+// 最近一次收到的原始帧副本
+func (r *Relay) rawFrame(t PropertyType) []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if raw, ok := r.raw[t]; ok {
+		return append([]byte{}, raw...)
+	}
+	return nil
+}
+
+// TH 当前温湿度，可在其他协程中调用
+func (r *Relay) TH() TemperatureAndHumidity {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.th
 }
@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HealthStatus 健康状态
+type HealthStatus int
+
+const (
+	// HealthOK 连接正常，数据及时，近期没有错误
+	HealthOK HealthStatus = iota
+	// HealthDegraded 仍在线，但数据过期或近期有错误
+	HealthDegraded
+	// HealthOffline 已下线或没有连接
+	HealthOffline
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthOK:
+		return "healthy"
+	case HealthDegraded:
+		return "degraded"
+	case HealthOffline:
+		return "offline"
+	}
+	return "unknown"
+}
+
+// MarshalText 以名称编码，JSON 中为字符串
+func (s HealthStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 按名称解码
+func (s *HealthStatus) UnmarshalText(text []byte) error {
+	for _, status := range []HealthStatus{HealthOK, HealthDegraded, HealthOffline} {
+		if string(text) == status.String() {
+			*s = status
+			return nil
+		}
+	}
+	return errors.Errorf("unknown health status %q", text)
+}
+
+// Health 健康检查结果
+type Health struct {
+	Status   HealthStatus `json:"status"`
+	Reasons  []string     `json:"reasons,omitempty"` // 非 HealthOK 的原因
+	LastSeen time.Time    `json:"lastSeen"`
+}
+
+// 默认数据过期阈值为询问与上报间隔的倍数
+const staleIntervals = 3
+
+// Health 汇总连接状态、最近收到数据的时间与最近的错误，得到健康状态。
+// 超过过期阈值没有收到有效帧时为 HealthDegraded，阈值为 HeartbeatTimeout，未配置时为询问间隔与 KeepAlive 中最长者的 3 倍，
+// 从未收到时从创建时间起算；阈值内有错误发送到 Errors 或熔断器未关闭时也为 HealthDegraded
+func (r *Relay) Health() Health {
+	h := Health{Status: HealthOK, LastSeen: r.LastSeen()}
+	if isClosed(r.closed) {
+		h.Status = HealthOffline
+		h.Reasons = append(h.Reasons, "offline")
+		return h
+	}
+	if r.conn() == nil {
+		h.Status = HealthOffline
+		h.Reasons = append(h.Reasons, "not connected")
+		return h
+	}
+	threshold := r.staleThreshold()
+	now := r.now()
+	if h.LastSeen.IsZero() {
+		if now.Sub(r.OnlineTime) > threshold {
+			h.Status = HealthDegraded
+			h.Reasons = append(h.Reasons, "no data received")
+		}
+	} else if since := now.Sub(h.LastSeen); since > threshold {
+		h.Status = HealthDegraded
+		h.Reasons = append(h.Reasons, fmt.Sprintf("stale data: last seen %s ago", since.Round(time.Millisecond)))
+	}
+	if s := r.BreakerState(); s != BreakerClosed {
+		h.Status = HealthDegraded
+		h.Reasons = append(h.Reasons, "circuit breaker "+s.String())
+	}
+	if err, at := r.lastError(); err != nil && now.Sub(at) <= threshold {
+		h.Status = HealthDegraded
+		h.Reasons = append(h.Reasons, "recent error: "+err.Error())
+	}
+	return h
+}
+
+// 数据过期阈值
+func (r *Relay) staleThreshold() time.Duration {
+	if r.heartbeatTimeout > 0 {
+		return r.heartbeatTimeout
+	}
+	d := r.keepAliveInterval()
+	for _, t := range []PropertyType{TH, INPUTSTATE} {
+		if interval := r.inquiryInterval(t); interval > d {
+			d = interval
+		}
+	}
+	return staleIntervals * d
+}
+
+// 最近一次发送到 Errors 的错误及其时间
+func (r *Relay) lastError() (error, time.Time) {
+	r.chanMu.Lock()
+	defer r.chanMu.Unlock()
+	return r.lastErr, r.lastErrAt
+}
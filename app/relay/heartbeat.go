@@ -0,0 +1,71 @@
+package relay
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HeartbeatTimeout 心跳超时配置，超过 d 没有收到有效帧则认为连接已失效并下线
+func HeartbeatTimeout(d time.Duration) Option {
+	return func(r *Relay) {
+		r.heartbeatTimeout = d
+	}
+}
+
+// 记录收到有效帧的时间
+func (r *Relay) touch() {
+	atomic.StoreInt64(&r.lastSeen, r.now().UnixNano())
+}
+
+// 重置心跳计时，上线或重连后重新等待一个心跳超时
+func (r *Relay) resetHeartbeat() {
+	atomic.StoreInt64(&r.aliveAt, r.now().UnixNano())
+}
+
+// LastSeen 最近一次收到有效帧的时间，从未收到时为零值
+func (r *Relay) LastSeen() time.Time {
+	if n := atomic.LoadInt64(&r.lastSeen); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// 心跳计时起点：最近一次收到有效帧或重置心跳的时间
+func (r *Relay) lastSeenTime() time.Time {
+	seen, at := atomic.LoadInt64(&r.lastSeen), atomic.LoadInt64(&r.aliveAt)
+	if at > seen {
+		seen = at
+	}
+	return time.Unix(0, seen)
+}
+
+// 是否存活，未配置心跳超时时总是存活
+func (r *Relay) alive() bool {
+	return r.heartbeatTimeout <= 0 || r.now().Sub(r.lastSeenTime()) <= r.heartbeatTimeout
+}
+
+// 心跳检测循环
+func (r *Relay) heartbeatLoop() {
+	for {
+		timer := r.clock.NewTimer(r.heartbeatTimeout / 2)
+		select {
+		case <-r.closed:
+			timer.Stop()
+			return
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+			if !r.alive() {
+				since := r.now().Sub(r.lastSeenTime())
+				r.log().Printf("设备 %d 心跳超时，%v 未收到数据", r.SubDeviceID, since)
+				err := errors.Errorf("heartbeat timeout: no frame for %v", since)
+				r.reportError(err)
+				r.offlineWith(OfflineHeartbeat, err)
+				return
+			}
+		}
+	}
+}
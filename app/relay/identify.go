@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported 设备或协议不支持该操作
+var ErrNotSupported = errors.New("not supported")
+
+// DeviceInfo 设备标识，设备未提供的字段为空，路数未知时为 0
+type DeviceInfo struct {
+	Vendor   string `json:"vendor"`
+	Model    string `json:"model"`
+	Firmware string `json:"firmware"`
+	Outputs  int    `json:"outputs"`
+	Inputs   int    `json:"inputs"`
+}
+
+// 存放路数的保持寄存器
+type channelRegisters struct {
+	outputs uint16
+	inputs  uint16
+}
+
+// ChannelRegisters 路数寄存器配置，Identify 读取保持寄存器 outputs、inputs 作为输出、输入路数。
+// 基本设备标识不包含路数，寄存器地址需查阅设备手册，未配置时 DeviceInfo 的路数为 0
+func ChannelRegisters(outputs, inputs uint16) Option {
+	return func(r *Relay) {
+		r.channelRegs = &channelRegisters{outputs: outputs, inputs: inputs}
+	}
+}
+
+// 基本设备标识对象编号
+const (
+	objectVendorName  byte = 0x00
+	objectProductCode byte = 0x01
+	objectRevision    byte = 0x02
+)
+
+// Identify 读取设备标识（Modbus 功能码 2B/0E 基本标识：厂商、型号、固件版本），
+// 配置了 ChannelRegisters 时再读取输出、输入路数。
+// 默认协议没有标识命令，也不支持从厂商寄存器读取标识，设备不支持或回复非法功能时返回 ErrNotSupported；
+// 不会根据标识自动配置帧长、字节序等解析参数，需由调用方按型号选择配置。
+func (r *Relay) Identify(ctx context.Context) (DeviceInfo, error) {
+	return r.IdentifyAt(ctx, r.SubDeviceID)
+}
+
+// IdentifyAt 通过本继电器的连接读取地址为 addr 的从站的设备标识，可用于逐个地址探测总线上的设备
+func (r *Relay) IdentifyAt(ctx context.Context, addr uint16) (DeviceInfo, error) {
+	if r.framing == FramingVendor {
+		return DeviceInfo{}, errors.Wrap(ErrNotSupported, "identify failed")
+	}
+	resp, err := r.call(ctx, ReadDeviceIDFrame(addr, 0x01, objectVendorName))
+	if e, ok := err.(*ModbusException); ok && e.Code == exceptionIllegalFunction {
+		return DeviceInfo{}, errors.Wrap(ErrNotSupported, "identify failed")
+	}
+	if err != nil {
+		return DeviceInfo{}, errors.Wrap(err, "identify failed")
+	}
+	objects, err := parseDeviceID(resp)
+	if err != nil {
+		return DeviceInfo{}, errors.Wrap(err, "identify failed")
+	}
+	info := DeviceInfo{
+		Vendor:   objects[objectVendorName],
+		Model:    objects[objectProductCode],
+		Firmware: objects[objectRevision],
+	}
+	if regs := r.channelRegs; regs != nil {
+		outputs, err := r.ReadRegisterAt(ctx, addr, regs.outputs)
+		if err != nil {
+			return DeviceInfo{}, errors.Wrap(err, "identify failed")
+		}
+		inputs, err := r.ReadRegisterAt(ctx, addr, regs.inputs)
+		if err != nil {
+			return DeviceInfo{}, errors.Wrap(err, "identify failed")
+		}
+		info.Outputs, info.Inputs = int(outputs), int(inputs)
+	}
+	return info, nil
+}
+
+// 解析读设备标识响应中的对象
+func parseDeviceID(resp []byte) (map[byte]string, error) {
+	if len(resp) < 10 || resp[2] != meiReadDeviceID {
+		return nil, protocolErrorf(ErrBadFrame, "bad device id response % X", resp)
+	}
+	objects := make(map[byte]string)
+	pos := 8
+	for i := 0; i < int(resp[7]); i++ {
+		if pos+2 > len(resp)-2 || pos+2+int(resp[pos+1]) > len(resp)-2 {
+			return nil, protocolErrorf(ErrBadFrame, "bad device id response % X", resp)
+		}
+		n := int(resp[pos+1])
+		objects[resp[pos]] = string(resp[pos+2 : pos+2+n])
+		pos += 2 + n
+	}
+	return objects, nil
+}
@@ -0,0 +1,84 @@
+package relay
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 未收到响应的查询超过该时间视为丢失，不再占用名额
+const inFlightExpiry = 5 * time.Second
+
+// MaxInFlight 限制未收到响应的查询数，n <= 0 不限制（默认）。
+// 按命令类型跟踪：同类查询未收到响应时跳过本次询问，
+// 未响应的查询达到 n 个时跳过其他询问，超过 5 秒未响应的查询视为丢失。
+func MaxInFlight(n int) Option {
+	return func(r *Relay) {
+		r.inFlight.max = n
+	}
+}
+
+// 未收到响应的查询
+type inFlight struct {
+	mu      sync.Mutex
+	max     int
+	pending map[PropertyType]time.Time
+}
+
+// 发送查询命令，被限制时跳过
+func (r *Relay) inquire(ctx context.Context, t PropertyType, cmd string) error {
+	r.checkAnswered(t)
+	if !r.inFlight.acquire(t) {
+		r.log().Printf("设备 %d %s 查询未响应，跳过本次询问", r.SubDeviceID, t)
+		return nil
+	}
+	if err := r.sendCommand(ctx, cmd); err != nil {
+		r.inFlight.release(t)
+		return err
+	}
+	r.mu.Lock()
+	r.requested[t] = r.now()
+	r.mu.Unlock()
+	r.breakerSent(t)
+	return nil
+}
+
+// 最近一次发送 t 类型查询的时间
+func (r *Relay) requestTime(t PropertyType) (time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	at, ok := r.requested[t]
+	return at, ok
+}
+
+// 占用名额
+func (f *inFlight) acquire(t PropertyType) bool {
+	if f.max <= 0 {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pending == nil {
+		f.pending = make(map[PropertyType]time.Time)
+	}
+	for pt, at := range f.pending {
+		if time.Since(at) > inFlightExpiry {
+			delete(f.pending, pt)
+		}
+	}
+	if _, ok := f.pending[t]; ok || len(f.pending) >= f.max {
+		return false
+	}
+	f.pending[t] = time.Now()
+	return true
+}
+
+// 收到响应或发送失败时释放名额
+func (f *inFlight) release(t PropertyType) {
+	if f.max <= 0 {
+		return
+	}
+	f.mu.Lock()
+	delete(f.pending, t)
+	f.mu.Unlock()
+}
@@ -0,0 +1,56 @@
+package relay
+
+import (
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// InitRetry 初始连接重试配置，与运行中的 Reconnect 区分。
+// New 时没有传入连接（conn 为 nil）时，Init 使用 Reconnect 配置的 dial 建立连接，
+// 失败后按 backoff 指数退避（最长 1 分钟）重试，共尝试 attempts 次，全部失败时返回最后一次的错误；
+// 未配置时只尝试一次。设备启动较慢、上电后短时间内不可达时使用。
+// dial 只能通过 Reconnect 配置，没有连接也没有配置 Reconnect 时 Init 不重试，返回 ErrNotConnected
+func InitRetry(attempts int, backoff time.Duration) Option {
+	return func(r *Relay) {
+		r.initAttempts = attempts
+		r.initBackoff = backoff
+	}
+}
+
+// 建立初始连接
+func (r *Relay) dialInit() error {
+	attempts := r.initAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := r.initBackoff
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := r.clock.NewTimer(delay)
+			select {
+			case <-r.closed:
+				timer.Stop()
+				return errors.Wrap(ErrRelayClosed, "dial failed")
+			case <-r.ctx.Done():
+				timer.Stop()
+				return errors.Wrap(r.ctx.Err(), "dial failed")
+			case <-timer.C():
+			}
+			if delay *= 2; delay > maxReconnectBackoff {
+				delay = maxReconnectBackoff
+			}
+		}
+		var conn net.Conn
+		if conn, err = r.dial(); err == nil {
+			r.connMu.Lock()
+			r.Conn = conn
+			r.connMu.Unlock()
+			return nil
+		}
+		r.log().Printf("设备 %d 第 %d 次连接失败: %v", r.SubDeviceID, i+1, err)
+	}
+	return errors.Wrapf(err, "dial failed after %d attempts", attempts)
+}
@@ -0,0 +1,23 @@
+package relay
+
+// OnInputEdge 输入跳变回调配置，输入从 0 变为 1 时 rising 为 true，从 1 变为 0 时为 false。
+// 与上一次读取的输入状态逐路比较，上线后的第一次读取没有上一次状态，不触发；
+// 回调与 OnPropertyChange 在同一协程中按顺序执行。
+func OnInputEdge(cb func(r *Relay, route uint8, rising bool)) Option {
+	return func(r *Relay) {
+		r.onInputEdge = cb
+	}
+}
+
+// 输入状态变化时通知跳变回调
+func (r *Relay) inputChanged(seen bool, old, new InputStates) {
+	if r.onInputEdge == nil || !seen {
+		return
+	}
+	for _, state := range old.Diff(new) {
+		route, rising := state.Route, state.Value == 1
+		r.dispatch(func() {
+			r.onInputEdge(r, route, rising)
+		})
+	}
+}
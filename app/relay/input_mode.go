@@ -0,0 +1,79 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// InputMode 输入模式
+type InputMode int
+
+// 常见的输入模式
+const (
+	// InputFloating 浮空输入，干接点或外部已有上下拉
+	InputFloating InputMode = iota
+	// InputPullUp 内部上拉
+	InputPullUp
+	// InputPullDown 内部下拉
+	InputPullDown
+	// InputCounter 脉冲计数
+	InputCounter
+)
+
+func (m InputMode) String() string {
+	switch m {
+	case InputFloating:
+		return "floating"
+	case InputPullUp:
+		return "pull-up"
+	case InputPullDown:
+		return "pull-down"
+	case InputCounter:
+		return "counter"
+	}
+	return "unknown"
+}
+
+// InputModeRegisters 输入模式寄存器配置：第 N 路的模式保持寄存器为 base+N-1，
+// values 为各模式写入的值，nil 时按枚举值写入（浮空 0、上拉 1、下拉 2、计数 3）。
+// 寄存器与取值因设备而异，需查阅设备手册；未配置时 ConfigureInput 返回 ErrNotSupported
+func InputModeRegisters(base uint16, values map[InputMode]uint16) Option {
+	return func(r *Relay) {
+		r.inputModes = &inputModeRegisters{base: base, values: values}
+	}
+}
+
+// 输入模式寄存器
+type inputModeRegisters struct {
+	base   uint16
+	values map[InputMode]uint16
+}
+
+// 模式对应的寄存器值，设备不支持该模式时返回 false
+func (m *inputModeRegisters) value(mode InputMode) (uint16, bool) {
+	if m.values == nil {
+		return uint16(mode), mode >= InputFloating && mode <= InputCounter
+	}
+	v, ok := m.values[mode]
+	return v, ok
+}
+
+// ConfigureInput 设置第 route 路的输入模式（Modbus 功能码 06），需要配置 InputModeRegisters 与 Modbus 分帧。
+// 未配置或 values 中没有该模式时返回 ErrNotSupported
+func (r *Relay) ConfigureInput(ctx context.Context, route uint8, mode InputMode) error {
+	if r.inputModes == nil {
+		return errors.Wrap(ErrNotSupported, "configure input failed")
+	}
+	if route == 0 {
+		return errors.Errorf("configure input failed: invalid route %d", route)
+	}
+	v, ok := r.inputModes.value(mode)
+	if !ok {
+		return errors.Wrapf(ErrNotSupported, "configure input %d as %s failed", route, mode)
+	}
+	if err := r.SetRegisterAt(ctx, r.SubDeviceID, r.inputModes.base+uint16(route)-1, v); err != nil {
+		return errors.Wrapf(err, "configure input %d as %s failed", route, mode)
+	}
+	return nil
+}
@@ -1,13 +1,48 @@
 package relay
 
+import (
+	"time"
+)
+
+// InquiryIntervals 询问间隔配置，分别设置温湿度和输入状态的询问间隔，未设置时使用 KeepAlive
+func InquiryIntervals(th, input time.Duration) Option {
+	return func(r *Relay) {
+		r.thInterval = th
+		r.inputInterval = input
+	}
+}
+
+// PassiveMode 被动模式，不启动询问循环，只读取设备主动上报的数据，
+// 上线与定时上报不受影响
+func PassiveMode() Option {
+	return func(r *Relay) {
+		r.passive = true
+	}
+}
+
+// 询问间隔，配置了 AdaptivePolling 时为自适应间隔，未设置时使用 keepAlive
+func (r *Relay) inquiryInterval(t PropertyType) time.Duration {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	if r.adaptive != nil {
+		return r.adaptive.current()
+	}
+	d := r.thInterval
+	if t == INPUTSTATE {
+		d = r.inputInterval
+	}
+	if d > 0 {
+		return d
+	}
+	return r.keepAlive
+}
+
 // InquiryTH 发送查询温湿度命令
-func (r *Relay) InquiryTH() {
-	cmd := "A0 01 08 2A 00 00 00 00 00 00 00 00 A7"
-	r.sendCommand(cmd)
+func (r *Relay) InquiryTH() error {
+	return r.inquire(r.ctx, TH, inquiryTHCommand)
 }
 
 // InquiryInputState 发送查询输入状态命令
-func (r *Relay) InquiryInputState() {
-	cmd := "A0 01 08 1B 00 00 00 00 00 00 00 00 A7"
-	r.sendCommand(cmd)
+func (r *Relay) InquiryInputState() error {
+	return r.inquire(r.ctx, INPUTSTATE, inquiryInputCommand)
 }
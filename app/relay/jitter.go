@@ -0,0 +1,64 @@
+package relay
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 抖动比例上限，保证间隔不低于原间隔的 10%
+const maxJitter = 0.9
+
+// 抖动使用的随机数，按启动时间播种，避免各进程的抖动序列相同
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// InquiryJitter 询问间隔抖动配置，每次间隔在 ±fraction 倍内随机变化，fraction 取值 [0, 0.9]，
+// 超出时取边界值，默认 0 不抖动。
+// 上线后第一次询问也随机推迟 [0, fraction) 倍间隔，避免大量继电器同时上线时询问同步；
+// 作用于询问循环与共享的 Scheduler，上报间隔不变
+func InquiryJitter(fraction float64) Option {
+	return func(r *Relay) {
+		if fraction < 0 {
+			fraction = 0
+		}
+		if fraction > maxJitter {
+			fraction = maxJitter
+		}
+		r.jitter = fraction
+	}
+}
+
+// [0, 1) 内的随机数
+func jitterFloat() float64 {
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return jitterRand.Float64()
+}
+
+// 当前抖动比例
+func (r *Relay) jitterFraction() float64 {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.jitter
+}
+
+// 加上随机抖动后的间隔
+func (r *Relay) jittered(d time.Duration) time.Duration {
+	f := r.jitterFraction()
+	if f <= 0 || d <= 0 {
+		return d
+	}
+	return d + time.Duration((2*jitterFloat()-1)*f*float64(d))
+}
+
+// 第一次询问前的随机延迟
+func (r *Relay) initialDelay(d time.Duration) time.Duration {
+	f := r.jitterFraction()
+	if f <= 0 || d <= 0 {
+		return 0
+	}
+	return time.Duration(jitterFloat() * f * float64(d))
+}
@@ -0,0 +1,26 @@
+package relay
+
+import (
+	"time"
+)
+
+// SetKeepAlive 运行中修改上报间隔并立即重新排期：询问循环立即询问一次，
+// 之后按新间隔（配置了 InquiryIntervals 时仍按其间隔）询问；上报循环从现在起按新间隔计时。
+// 可在任意协程调用，与 Apply(KeepAlive(d)) 的区别是不等待当前定时器到期；d <= 0 时使用默认值 5 秒
+func (r *Relay) SetKeepAlive(d time.Duration) {
+	r.cfgMu.Lock()
+	r.keepAlive = keepAliveOrDefault(d)
+	close(r.retick)
+	r.retick = make(chan struct{})
+	r.cfgMu.Unlock()
+	if r.scheduler != nil {
+		r.scheduler.reschedule(r)
+	}
+}
+
+// 间隔被 SetKeepAlive 修改时关闭的通道
+func (r *Relay) retickChan() <-chan struct{} {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.retick
+}
@@ -0,0 +1,41 @@
+package relay
+
+// Labels 输出、输入路数名称配置，只用于展示，随快照和属性更新事件一起输出
+func Labels(output map[uint8]string, input map[uint8]string) Option {
+	return func(r *Relay) {
+		r.outputLabels = copyLabels(output)
+		r.inputLabels = copyLabels(input)
+	}
+}
+
+// OutputLabel 输出路数名称，未配置时为空
+func (r *Relay) OutputLabel(route uint8) string {
+	return r.outputLabels[route]
+}
+
+// InputLabel 输入路数名称，未配置时为空
+func (r *Relay) InputLabel(route uint8) string {
+	return r.inputLabels[route]
+}
+
+// 属性类型对应的名称
+func (r *Relay) labels(t PropertyType) map[uint8]string {
+	switch t {
+	case OUTPUTSTATE:
+		return copyLabels(r.outputLabels)
+	case INPUTSTATE:
+		return copyLabels(r.inputLabels)
+	}
+	return nil
+}
+
+func copyLabels(labels map[uint8]string) map[uint8]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	m := make(map[uint8]string, len(labels))
+	for k, v := range labels {
+		m[k] = v
+	}
+	return m
+}
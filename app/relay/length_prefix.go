@@ -0,0 +1,52 @@
+package relay
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// LengthPrefix 变长帧配置，用于帧长由帧头中的长度字段给出的继电器型号。
+// 长度字段位于 offset 处，占 size 字节（1 或 2，大端），帧长为字段值加 adjust
+// （字段只计数据部分时 adjust 为帧头、帧尾等固定部分的长度）。
+// 配置后 FrameLength 为最大帧长，帧长不合法时按错误帧处理并逐字节重新对齐；
+// 帧校验与解码仍按 Checksum、CRC16 和 WithDecoder 的配置。只用于默认协议，Modbus 分帧不受影响
+func LengthPrefix(offset, size, adjust int) Option {
+	return func(r *Relay) {
+		r.lengthPrefix = &lengthPrefix{offset: offset, size: size, adjust: adjust}
+	}
+}
+
+// 长度字段配置
+type lengthPrefix struct {
+	offset int
+	size   int
+	adjust int
+}
+
+// 检查长度字段配置
+func (p *lengthPrefix) validate() error {
+	if p.offset < 0 || (p.size != 1 && p.size != 2) {
+		return errors.Errorf("bad length prefix: offset %d, size %d", p.offset, p.size)
+	}
+	return nil
+}
+
+// 按长度字段计算帧长，不超过 max
+func (p *lengthPrefix) frameSize(max int) func(head []byte) (int, error) {
+	return func(head []byte) (int, error) {
+		need := p.offset + p.size
+		if len(head) < need {
+			return need, nil
+		}
+		value := int(head[p.offset])
+		if p.size == 2 {
+			value = int(binary.BigEndian.Uint16(head[p.offset:]))
+		}
+		n := value + p.adjust
+		if n < need || n > max {
+			return 0, protocolErrorf(ErrBadFrame, "bad frame length %d, want %d to %d", n, need, max)
+		}
+		return n, nil
+	}
+}
@@ -0,0 +1,31 @@
+package relay
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Logger 日志接口，*log.Logger 可直接使用
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// WithLogger 日志配置，默认带时间前缀输出到标准输出
+func WithLogger(l Logger) Option {
+	return func(r *Relay) {
+		r.logger = l
+	}
+}
+
+// 默认日志，输出到标准输出
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stdout, "%v %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// 丢弃全部输出的日志
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"sync"
+)
+
+// Manager 继电器注册表，按子设备 ID 管理多个继电器，可在多个协程中使用
+type Manager struct {
+	mu     sync.RWMutex
+	relays map[uint16]*Relay
+}
+
+// NewManager 创建继电器注册表
+func NewManager() *Manager {
+	return &Manager{relays: make(map[uint16]*Relay)}
+}
+
+// Add 添加继电器，已存在相同 ID 时替换。
+// 需在 Online 之前调用，继电器下线时会自动移除，原有的 OfflineCallbackFn 仍会执行。
+func (m *Manager) Add(r *Relay) {
+	cb := r.OfflineCallbackFn
+	r.OfflineCallbackFn = func(relay *Relay) {
+		m.remove(relay)
+		if cb != nil {
+			cb(relay)
+		}
+	}
+	m.mu.Lock()
+	m.relays[r.SubDeviceID] = r
+	m.mu.Unlock()
+}
+
+// Remove 移除继电器
+func (m *Manager) Remove(id uint16) {
+	m.mu.Lock()
+	delete(m.relays, id)
+	m.mu.Unlock()
+}
+
+// Get 获取继电器
+func (m *Manager) Get(id uint16) (*Relay, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.relays[id]
+	return r, ok
+}
+
+// Each 遍历所有继电器，fn 中可以调用 Manager 的其他方法
+func (m *Manager) Each(fn func(*Relay)) {
+	for _, r := range m.list() {
+		fn(r)
+	}
+}
+
+// Count 继电器数量
+func (m *Manager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.relays)
+}
+
+// 继电器列表
+func (m *Manager) list() []*Relay {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	relays := make([]*Relay, 0, len(m.relays))
+	for _, r := range m.relays {
+		relays = append(relays, r)
+	}
+	return relays
+}
+
+// 移除指定继电器，ID 已被新继电器替换时不移除
+func (m *Manager) remove(r *Relay) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.relays[r.SubDeviceID] == r {
+		delete(m.relays, r.SubDeviceID)
+	}
+}
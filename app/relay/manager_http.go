@@ -0,0 +1,73 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Status 继电器运行状态，Handler 返回的 JSON 结构
+type Status struct {
+	Snapshot
+	Uptime string `json:"uptime"`
+	Stats  Stats  `json:"stats"` // 包含最近一次收到数据的时间
+	Health Health `json:"health"`
+}
+
+// Status 获取运行状态：状态快照、在线时长、运行统计与健康状态
+func (r *Relay) Status() Status {
+	return Status{
+		Snapshot: r.Snapshot(),
+		Uptime:   r.Uptime().Round(time.Second).String(),
+		Stats:    r.Stats(),
+		Health:   r.Health(),
+	}
+}
+
+// Handler 以 JSON 返回继电器运行状态的 HTTP 处理器，只支持 GET：
+// 路径为 / 时返回所有继电器按子设备 ID 排序的列表，路径最后一段为十进制子设备 ID 时返回单个继电器，
+// 挂载到子路径时配合 http.StripPrefix 使用，如 mux.Handle("/relays/", http.StripPrefix("/relays", m.Handler()))
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := path.Base(path.Clean("/" + req.URL.Path))
+		if name == "/" {
+			relays := m.list()
+			sort.Slice(relays, func(i, j int) bool { return relays[i].SubDeviceID < relays[j].SubDeviceID })
+			list := make([]Status, 0, len(relays))
+			for _, r := range relays {
+				list = append(list, r.Status())
+			}
+			writeJSON(rw, list)
+			return
+		}
+		id, err := strconv.ParseUint(name, 10, 16)
+		if err != nil {
+			http.Error(rw, "bad device id "+name, http.StatusBadRequest)
+			return
+		}
+		r, ok := m.Get(uint16(id))
+		if !ok {
+			http.Error(rw, "device not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(rw, r.Status())
+	})
+}
+
+// 写入 JSON 响应
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
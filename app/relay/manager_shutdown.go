@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Shutdown 同时下线的继电器数量上限
+const shutdownConcurrency = 32
+
+// ShutdownError Shutdown 超时，IDs 为未在期限内退出的继电器
+type ShutdownError struct {
+	IDs []uint16 // 按子设备 ID 排序
+	Err error    // ctx.Err()
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown: %d relays not drained %v: %v", len(e.IDs), e.IDs, e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Shutdown 并发下线所有继电器（最多同时 32 个）并等待后台协程退出（见 Wait），
+// 全部退出时返回 nil；ctx 结束时返回 *ShutdownError，列出未退出的继电器，
+// 这些继电器仍会在后台继续下线，尚未开始下线的继电器直接下线，不再等待
+func (m *Manager) Shutdown(ctx context.Context) error {
+	relays := m.list()
+	sort.Slice(relays, func(i, j int) bool {
+		return relays[i].SubDeviceID < relays[j].SubDeviceID
+	})
+	sem := make(chan struct{}, shutdownConcurrency)
+	done := make([]chan struct{}, len(relays))
+	for i, r := range relays {
+		done[i] = make(chan struct{})
+		go func(r *Relay, done chan struct{}) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				r.Offline()
+				return
+			}
+			r.Offline()
+			r.Wait()
+			close(done)
+		}(r, done[i])
+	}
+	var failed []uint16
+	for i, r := range relays {
+		select {
+		case <-done[i]:
+		case <-ctx.Done():
+			select {
+			case <-done[i]:
+			default:
+				failed = append(failed, r.SubDeviceID)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return &ShutdownError{IDs: failed, Err: ctx.Err()}
+	}
+	return nil
+}
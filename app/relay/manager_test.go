@@ -0,0 +1,114 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager()
+	called := false
+	r1 := New(nil, &net.TCPConn{}, 1, KeepAlive(time.Second), OfflineCallback(func(*Relay) {
+		called = true
+	}))
+	r2 := New(nil, &net.TCPConn{}, 2, KeepAlive(time.Second))
+	m.Add(r1)
+	m.Add(r2)
+	if m.Count() != 2 {
+		t.Fatalf("Count = %d, want 2", m.Count())
+	}
+	if r, ok := m.Get(2); !ok || r != r2 {
+		t.Fatal("Get(2) failed")
+	}
+	n := 0
+	m.Each(func(*Relay) { n++ })
+	if n != 2 {
+		t.Fatalf("Each visited %d relays", n)
+	}
+
+	r1.Offline()
+	if _, ok := m.Get(1); ok || !called {
+		t.Fatal("offline relay not removed or callback not called")
+	}
+	m.Remove(2)
+	if m.Count() != 0 {
+		t.Fatalf("Count = %d, want 0", m.Count())
+	}
+}
+
+func TestManagerHandler(t *testing.T) {
+	m := NewManager()
+	m.Add(New(nil, nil, 2))
+	m.Add(New(nil, nil, 1))
+	h := m.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var list []Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].SubDeviceID != 1 || list[1].SubDeviceID != 2 {
+		t.Fatalf("list = %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/2", nil))
+	var status Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.SubDeviceID != 2 || status.Uptime == "" {
+		t.Fatalf("status = %+v", status)
+	}
+
+	for target, code := range map[string]int{"/3": http.StatusNotFound, "/x": http.StatusBadRequest} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != code {
+			t.Fatalf("GET %s = %d, want %d", target, rec.Code, code)
+		}
+	}
+}
+
+func TestManagerShutdown(t *testing.T) {
+	m := NewManager()
+	for id := uint16(1); id <= 3; id++ {
+		r := New(nil, NewSimConn(id), id, KeepAlive(time.Hour))
+		m.Add(r)
+		if err := r.Online([]PropertyType{OUTPUTSTATE}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	release := make(chan struct{})
+	defer close(release)
+	stuck := New(nil, NewSimConn(9), 9, KeepAlive(time.Hour), OfflineCallbackTimeout(time.Minute), OfflineCallback(func(*Relay) {
+		<-release
+	}))
+	m.Add(stuck)
+	if err := stuck.Online([]PropertyType{OUTPUTSTATE}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := m.Shutdown(ctx)
+	var se *ShutdownError
+	if !errors.As(err, &se) || len(se.IDs) != 1 || se.IDs[0] != 9 {
+		t.Fatalf("Shutdown = %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want deadline exceeded", err)
+	}
+
+	if err := NewManager().Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,73 @@
+package relay
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Middleware 中间件，发送属性前处理数据。
+// 中间件按 Use 和 Middlewares 传入的顺序执行，返回 false 时终止执行，
+// 后续中间件不再收到该数据，本次属性也不会发送。
+type Middleware func(*Relay, Data) (Data, bool)
+
+// 按设备和属性类型区分的中间件状态
+type middlewareKey struct {
+	subDeviceID  uint16
+	propertyType PropertyType
+}
+
+// DedupMiddleware 去重中间件，丢弃与同一设备同一属性类型上一次数据相同的数据
+func DedupMiddleware() Middleware {
+	var mu sync.Mutex
+	last := map[middlewareKey]interface{}{}
+	return func(r *Relay, data Data) (Data, bool) {
+		key := middlewareKey{r.SubDeviceID, data.PropertyType}
+		mu.Lock()
+		defer mu.Unlock()
+		if prev, ok := last[key]; ok && reflect.DeepEqual(prev, data.Data) {
+			return data, false
+		}
+		last[key] = data.Data
+		return data, true
+	}
+}
+
+// RateLimitMiddleware 限流中间件，同一设备同一属性类型在 d 内最多通过一次，其余丢弃
+func RateLimitMiddleware(d time.Duration) Middleware {
+	var mu sync.Mutex
+	lastSent := map[middlewareKey]time.Time{}
+	return func(r *Relay, data Data) (Data, bool) {
+		key := middlewareKey{r.SubDeviceID, data.PropertyType}
+		now := r.now()
+		mu.Lock()
+		defer mu.Unlock()
+		if sent, ok := lastSent[key]; ok && now.Sub(sent) < d {
+			return data, false
+		}
+		lastSent[key] = now
+		return data, true
+	}
+}
+
+// TimestampMiddleware 时间戳中间件，未收到过的数据以当前时间作为 Time；
+// 数据是对查询（InquiryTH、QueryTH 等）的响应时计算往返时间写入 Latency，并记录到 Stats。
+// 协议没有请求编号，响应按属性类型对应到之前最近一次发送的同类查询，超过 5 秒视为无法对应
+func TimestampMiddleware() Middleware {
+	return func(r *Relay, data Data) (Data, bool) {
+		if data.Time.IsZero() {
+			data.Time = r.now()
+			return data, true
+		}
+		sent, ok := r.requestTime(data.PropertyType)
+		if !ok || sent.After(data.Time) {
+			return data, true
+		}
+		if latency := data.Time.Sub(sent); latency < inFlightExpiry {
+			data.Latency = latency
+			atomic.StoreInt64(&r.counters.latency, int64(latency))
+		}
+		return data, true
+	}
+}
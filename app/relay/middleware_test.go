@@ -0,0 +1,266 @@
+package relay
+
+import (
+	"bytes"
+	"path/filepath"
+	"relay/app/relay/relaytest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestMiddlewareDrop(t *testing.T) {
+	var order []int
+	drop := func(_ *Relay, data Data) (Data, bool) {
+		order = append(order, 1)
+		return data, data.PropertyType != TH
+	}
+	after := func(_ *Relay, data Data) (Data, bool) {
+		order = append(order, 2)
+		return data, true
+	}
+	r := New(nil, nil, 0x1001, Middlewares(drop, after))
+	if p := r.GetTH(); p != nil {
+		t.Fatalf("dropped property = %v, want nil", p)
+	}
+	if len(order) != 1 {
+		t.Fatalf("later middleware saw dropped data: %v", order)
+	}
+	if p := r.GetInputState(); p == nil {
+		t.Fatal("passed property is nil")
+	}
+	if len(order) != 3 || order[1] != 1 || order[2] != 2 {
+		t.Fatalf("middleware order %v", order)
+	}
+}
+
+func TestClearMiddlewares(t *testing.T) {
+	drop := func(_ *Relay, data Data) (Data, bool) {
+		return data, false
+	}
+	r := New(nil, nil, 0x1001, Middlewares(drop))
+	r.Use(DedupMiddleware())
+	if n := len(r.Middlewares()); n != 2 {
+		t.Fatalf("%d middlewares, want 2", n)
+	}
+	if p := r.GetTH(); p != nil {
+		t.Fatalf("dropped property = %v, want nil", p)
+	}
+	r.ClearMiddlewares()
+	if len(r.Middlewares()) != 0 || r.GetTH() == nil {
+		t.Fatal("middlewares not cleared")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.GetInputState()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		r.Use(TimestampMiddleware())
+		if i%10 == 0 {
+			r.ClearMiddlewares()
+		}
+	}
+	wg.Wait()
+}
+
+func TestDedupMiddleware(t *testing.T) {
+	r := New(nil, nil, 0x1001, Middlewares(DedupMiddleware()))
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	if r.GetTH() == nil {
+		t.Fatal("first reading dropped")
+	}
+	if r.GetInputState() == nil {
+		t.Fatal("other property type dropped")
+	}
+	if r.GetTH() != nil {
+		t.Fatal("repeated reading not dropped")
+	}
+	frame[5] = 0x1A
+	r.SaveTH(frame)
+	if r.GetTH() == nil {
+		t.Fatal("changed reading dropped")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	r := New(nil, nil, 0x1001, Middlewares(RateLimitMiddleware(time.Hour)))
+	if r.GetTH() == nil {
+		t.Fatal("first data dropped")
+	}
+	if r.GetInputState() == nil {
+		t.Fatal("other property type dropped")
+	}
+	if r.GetTH() != nil {
+		t.Fatal("data within interval not dropped")
+	}
+}
+
+func TestMiddlewareRawCopy(t *testing.T) {
+	mutate := func(_ *Relay, data Data) (Data, bool) {
+		data.Raw[0] = 0
+		return data, true
+	}
+	r := New(nil, nil, 0x1001, Middlewares(mutate))
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	r.GetTH()
+	r.GetTH()
+	if raw := r.rawFrame(TH); raw[0] != frameHead {
+		t.Fatalf("raw frame mutated by middleware: % X", raw)
+	}
+}
+
+func TestTimestampMiddleware(t *testing.T) {
+	var got Data
+	capture := func(_ *Relay, data Data) (Data, bool) {
+		got = data
+		return data, true
+	}
+	r := New(nil, relaytest.NewFakeConn(), 0x1001, Middlewares(TimestampMiddleware(), capture))
+	r.GetInputState()
+	if got.Time.IsZero() || got.Latency != 0 {
+		t.Fatalf("unreceived data %+v", got)
+	}
+	if err := r.InquiryTH(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	r.GetTH()
+	if got.Latency < 5*time.Millisecond {
+		t.Fatalf("latency %v, want >= 5ms", got.Latency)
+	}
+	if r.Stats().Latency != got.Latency {
+		t.Fatalf("stats latency %v, want %v", r.Stats().Latency, got.Latency)
+	}
+}
+
+func TestWriteMiddlewares(t *testing.T) {
+	conn := relaytest.NewFakeConn()
+	var logged [][]byte
+	logWrites := func(_ *Relay, frame []byte) ([]byte, error) {
+		logged = append(logged, frame)
+		return frame, nil
+	}
+	rewrite := func(_ *Relay, frame []byte) ([]byte, error) {
+		out := append([]byte{}, frame...)
+		out[2] = 0x02
+		return out, nil
+	}
+	r := New(nil, conn, 0x1001, WriteMiddlewares(logWrites, rewrite))
+	if err := r.SetOutput(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := commandFormatter("A0 01 08 2B 00 01 01 00 00 00 00 00 A7")
+	if len(logged) != 1 || !bytes.Equal(logged[0], want) {
+		t.Fatalf("logged %X", logged)
+	}
+	want[2] = 0x02
+	if writes := conn.Writes(); len(writes) != 1 || !bytes.Equal(writes[0], want) {
+		t.Fatalf("written % X, want % X", writes, want)
+	}
+	reject := errors.New("rejected")
+	r = New(nil, conn, 0x1001, WriteMiddlewares(func(*Relay, []byte) ([]byte, error) { return nil, reject }))
+	if err := r.InquiryTH(); errors.Cause(err) != reject {
+		t.Fatalf("inquiry error %v, want %v", err, reject)
+	}
+	if n := len(conn.Writes()); n != 1 {
+		t.Fatalf("%d writes after rejected frame", n)
+	}
+}
+
+// 阻塞直到 release 关闭的存储
+type slowStore struct {
+	*MemoryStore
+	release chan struct{}
+	saves   int32
+}
+
+func (s *slowStore) Save(id uint16, snap Snapshot) error {
+	<-s.release
+	atomic.AddInt32(&s.saves, 1)
+	return s.MemoryStore.Save(id, snap)
+}
+
+func TestPersistMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	r := New(nil, nil, 0x1001, Middlewares(PersistMiddleware(store)))
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	r.GetTH()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if snap, ok := store.Load(0x1001); ok && snap.TH == r.TH() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot not saved on get")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPersistTo(t *testing.T) {
+	store := &slowStore{MemoryStore: NewMemoryStore(), release: make(chan struct{})}
+	r := New(nil, nil, 0x1001, PersistTo(store))
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	done := make(chan struct{})
+	go func() {
+		// 存储阻塞时不影响读取，不需要上报或 Get* 也会写入
+		for i := 0; i < 3; i++ {
+			frame[5]++
+			r.SaveTH(frame)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("save blocked by store")
+	}
+	close(store.release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		snap, ok := store.Load(0x1001)
+		if ok && snap.TH == r.TH() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("latest snapshot not saved: %+v", snap)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// 写入被合并，少于变化次数
+	if n := atomic.LoadInt32(&store.saves); n >= 4 {
+		t.Fatalf("%d saves, want coalesced", n)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := store.Load(1); ok || err != nil {
+		t.Fatalf("load missing snapshot: %v, %v", ok, err)
+	}
+	snap := Snapshot{SubDeviceID: 1, Outputs: OutputStates{{Route: 1, Value: 1}}, TH: TemperatureAndHumidity{Temperature: 25.5}}
+	if err := store.Save(1, snap); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := store.Load(1)
+	if err != nil || !ok || got.TH != snap.TH || !got.Outputs.Equal(snap.Outputs) {
+		t.Fatalf("loaded %+v, %v, %v", got, ok, err)
+	}
+}
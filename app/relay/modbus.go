@@ -0,0 +1,95 @@
+package relay
+
+import (
+	"encoding/binary"
+)
+
+// Modbus 功能码
+const (
+	FuncReadCoils              byte = 0x01
+	FuncReadDiscreteInputs     byte = 0x02
+	FuncReadHoldingRegisters   byte = 0x03
+	FuncReadInputRegisters     byte = 0x04
+	FuncWriteSingleCoil        byte = 0x05
+	FuncWriteSingleRegister    byte = 0x06
+	FuncWriteMultipleCoils     byte = 0x0F
+	FuncWriteMultipleRegisters byte = 0x10
+	FuncReadDeviceID           byte = 0x2B
+)
+
+// 读设备标识的 MEI 类型
+const meiReadDeviceID byte = 0x0E
+
+// Modbus RTU 帧构造，addr 为从站地址（1~247，取低字节），帧尾附加 CRC-16/Modbus
+
+// ReadCoilsFrame 读线圈
+func ReadCoilsFrame(addr uint16, start, count uint16) []byte {
+	return rtuFrame(addr, FuncReadCoils, uint16s(start, count)...)
+}
+
+// ReadInputsFrame 读离散输入
+func ReadInputsFrame(addr uint16, start, count uint16) []byte {
+	return rtuFrame(addr, FuncReadDiscreteInputs, uint16s(start, count)...)
+}
+
+// ReadHoldingRegistersFrame 读保持寄存器
+func ReadHoldingRegistersFrame(addr uint16, start, count uint16) []byte {
+	return rtuFrame(addr, FuncReadHoldingRegisters, uint16s(start, count)...)
+}
+
+// ReadInputRegistersFrame 读输入寄存器
+func ReadInputRegistersFrame(addr uint16, start, count uint16) []byte {
+	return rtuFrame(addr, FuncReadInputRegisters, uint16s(start, count)...)
+}
+
+// WriteCoilFrame 写单个线圈
+func WriteCoilFrame(addr uint16, coil uint16, on bool) []byte {
+	var value uint16
+	if on {
+		value = 0xFF00
+	}
+	return rtuFrame(addr, FuncWriteSingleCoil, uint16s(coil, value)...)
+}
+
+// WriteRegisterFrame 写单个保持寄存器
+func WriteRegisterFrame(addr uint16, reg uint16, value uint16) []byte {
+	return rtuFrame(addr, FuncWriteSingleRegister, uint16s(reg, value)...)
+}
+
+// WriteCoilsFrame 写多个线圈，values[i] 对应 start+i
+func WriteCoilsFrame(addr uint16, start uint16, values []bool) []byte {
+	bits := make([]byte, (len(values)+7)/8)
+	for i, on := range values {
+		if on {
+			bits[i/8] |= 1 << uint(i%8)
+		}
+	}
+	data := append(uint16s(start, uint16(len(values))), byte(len(bits)))
+	return rtuFrame(addr, FuncWriteMultipleCoils, append(data, bits...)...)
+}
+
+// WriteRegistersFrame 写多个保持寄存器，values[i] 对应 start+i
+func WriteRegistersFrame(addr uint16, start uint16, values []uint16) []byte {
+	data := append(uint16s(start, uint16(len(values))), byte(len(values)*2))
+	return rtuFrame(addr, FuncWriteMultipleRegisters, append(data, uint16s(values...)...)...)
+}
+
+// ReadDeviceIDFrame 读设备标识，code 为读取类型（1 基本、2 常规、3 扩展、4 单个对象），objectID 为起始对象
+func ReadDeviceIDFrame(addr uint16, code byte, objectID byte) []byte {
+	return rtuFrame(addr, FuncReadDeviceID, meiReadDeviceID, code, objectID)
+}
+
+// 构造 RTU 帧：地址、功能码、数据、CRC
+func rtuFrame(addr uint16, fn byte, data ...byte) []byte {
+	frame := append([]byte{byte(addr), fn}, data...)
+	return AppendCRC16(frame)
+}
+
+// 大端编码
+func uint16s(values ...uint16) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
@@ -0,0 +1,771 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestModbusFrames(t *testing.T) {
+	cases := []struct {
+		name  string
+		frame []byte
+		want  string
+	}{
+		{"read holding registers", ReadHoldingRegistersFrame(0x01, 0x0000, 0x000A), "01 03 00 00 00 0A C5 CD"},
+		{"read inputs", ReadInputsFrame(0x11, 0x00C4, 0x0016), "11 02 00 C4 00 16 BA A9"},
+		{"write coil", WriteCoilFrame(0x11, 0x00AC, true), "11 05 00 AC FF 00 4E 8B"},
+		{"write register", WriteRegisterFrame(0x11, 0x0001, 0x0003), "11 06 00 01 00 03 9A 9B"},
+		{"write coils", WriteCoilsFrame(0x11, 0x0013, []bool{true, false, true, true, false, false, true, true, true, false}), "11 0F 00 13 00 0A 02 CD 01 BF 0B"},
+	}
+	for _, c := range cases {
+		want, _ := commandFormatter(c.want)
+		if !bytes.Equal(c.frame, want) {
+			t.Errorf("%s = % X, want %s", c.name, c.frame, c.want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		req := make([]byte, 8)
+		// 写寄存器回显请求
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		client.Write(req)
+		// 读寄存器，先发送一个错位字节
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		client.Write(append([]byte{0x00}, AppendCRC16([]byte{0x01, 0x03, 0x02, 0x12, 0x34})...))
+		// 异常响应
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		client.Write(AppendCRC16([]byte{0x01, 0x83, 0x02}))
+	}()
+	if err := r.SetRegister(0x0001, 0x0003); err != nil {
+		t.Fatal(err)
+	}
+	v, err := r.ReadRegister(0x0002)
+	if err != nil || v != 0x1234 {
+		t.Fatalf("read register %04X, %v", v, err)
+	}
+	_, err = r.ReadRegister(0x0100)
+	if e, ok := errors.Cause(err).(*ModbusException); !ok || e.Code != 0x02 {
+		t.Fatalf("want exception, got %v", err)
+	}
+}
+
+func TestRegisterNotModbus(t *testing.T) {
+	r := New(nil, nil, 0x01, KeepAlive(time.Second))
+	if err := r.SetRegister(1, 1); errors.Cause(err) != ErrNotModbus {
+		t.Fatalf("set register on vendor framing: %v", err)
+	}
+}
+
+func TestTransactionRouting(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		req := make([]byte, 8)
+		for i := 0; i < 2; i++ {
+			if _, err := io.ReadFull(client, req); err != nil {
+				return
+			}
+		}
+		// 后发的写寄存器请求先响应
+		client.Write(AppendCRC16([]byte{0x01, 0x06, 0x00, 0x02, 0x00, 0x07}))
+		client.Write(AppendCRC16([]byte{0x01, 0x03, 0x02, 0x00, 0x2A}))
+	}()
+	read := make(chan uint16, 1)
+	go func() {
+		v, err := r.ReadRegister(0x0001)
+		if err != nil {
+			t.Error(err)
+		}
+		read <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := r.SetRegister(0x0002, 0x0007); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-read; v != 0x2A {
+		t.Fatalf("read register %04X", v)
+	}
+}
+
+func TestByteOrder(t *testing.T) {
+	b := []byte{0x01, 0x02, 0xFF, 0x00}
+	big := New(nil, nil, 0x01, KeepAlive(time.Second))
+	little := New(nil, nil, 0x01, KeepAlive(time.Second), ByteOrder(binary.LittleEndian))
+	if got := big.decodeRegisters(b); got[0] != 0x0102 || got[1] != 0xFF00 {
+		t.Fatalf("big endian % X", got)
+	}
+	if got := little.decodeRegisters(b); got[0] != 0x0201 || got[1] != 0x00FF {
+		t.Fatalf("little endian % X", got)
+	}
+	if got := little.encodeRegister(0x0102); got != 0x0201 {
+		t.Fatalf("little endian encode %04X", got)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		req := make([]byte, 7)
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		resp := []byte{0x01, 0x2B, 0x0E, 0x01, 0x01, 0x00, 0x00, 0x03}
+		for i, v := range []string{"ACME", "R8", "1.2"} {
+			resp = append(append(resp, byte(i), byte(len(v))), v...)
+		}
+		client.Write(AppendCRC16(resp))
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		client.Write(AppendCRC16([]byte{0x01, 0xAB, 0x01}))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	info, err := r.Identify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != (DeviceInfo{Vendor: "ACME", Model: "R8", Firmware: "1.2"}) {
+		t.Fatalf("device info %+v", info)
+	}
+	if _, err := r.Identify(ctx); errors.Cause(err) != ErrNotSupported {
+		t.Fatalf("illegal function: %v", err)
+	}
+	vendor := New(nil, nil, 0x01, KeepAlive(time.Second))
+	if _, err := vendor.Identify(ctx); errors.Cause(err) != ErrNotSupported {
+		t.Fatalf("vendor framing: %v", err)
+	}
+
+	// 配置路数寄存器时读取输出、输入路数
+	client2, server2 := net.Pipe()
+	defer client2.Close()
+	counted := New(nil, server2, 0x01, KeepAlive(time.Second), Framing(FramingRTU), ChannelRegisters(0x0100, 0x0101))
+	if err := counted.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(counted)
+	requests := make(chan []byte, 2)
+	go func() {
+		req := make([]byte, 7)
+		if _, err := io.ReadFull(client2, req); err != nil {
+			return
+		}
+		client2.Write(AppendCRC16([]byte{0x01, 0x2B, 0x0E, 0x01, 0x01, 0x00, 0x00, 0x01, 0x01, 0x02, 'R', '8'}))
+		for _, n := range []byte{8, 4} {
+			req := make([]byte, 8)
+			if _, err := io.ReadFull(client2, req); err != nil {
+				return
+			}
+			requests <- req
+			client2.Write(AppendCRC16([]byte{0x01, 0x03, 0x02, 0x00, n}))
+		}
+	}()
+	info, err = counted.Identify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != (DeviceInfo{Model: "R8", Outputs: 8, Inputs: 4}) {
+		t.Fatalf("device info %+v", info)
+	}
+	for _, want := range [][]byte{ReadHoldingRegistersFrame(0x01, 0x0100, 1), ReadHoldingRegistersFrame(0x01, 0x0101, 1)} {
+		if req := <-requests; !bytes.Equal(req, want) {
+			t.Fatalf("request % X, want % X", req, want)
+		}
+	}
+}
+
+func TestShortMBAPResponse(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, Framing(FramingTCP))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		req := make([]byte, 12)
+		for {
+			if _, err := io.ReadFull(client, req); err != nil {
+				return
+			}
+			// 字节数与实际长度不符
+			switch req[7] {
+			case FuncReadInputRegisters:
+				client.Write([]byte{req[0], req[1], 0x00, 0x00, 0x00, 0x03, 0x01, 0x04, 0xFA})
+			default:
+				client.Write([]byte{req[0], req[1], 0x00, 0x00, 0x00, 0x04, 0x01, 0x03, 0x02, 0x01})
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := r.QueryAnalogInputsAt(ctx, 0x01, 0, 125); !errors.Is(err, ErrShortFrame) {
+		t.Fatalf("short analog response: %v", err)
+	}
+	if _, err := r.ReadRegisterContext(ctx, 0x0010); !errors.Is(err, ErrShortFrame) {
+		t.Fatalf("short register response: %v", err)
+	}
+}
+
+func TestModbusTCP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, Framing(FramingTCP))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		// 读取两个请求后倒序回复，按事务号匹配
+		var reqs [][]byte
+		for i := 0; i < 2; i++ {
+			req := make([]byte, 12)
+			if _, err := io.ReadFull(client, req); err != nil {
+				return
+			}
+			if req[2] != 0 || req[3] != 0 || req[5] != 6 || req[6] != 0x01 || req[7] != FuncReadHoldingRegisters {
+				t.Errorf("bad mbap request % X", req)
+			}
+			reqs = append(reqs, req)
+		}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			req := reqs[i]
+			// 响应值为请求的寄存器地址
+			client.Write([]byte{req[0], req[1], 0x00, 0x00, 0x00, 0x05, 0x01, 0x03, 0x02, req[8], req[9]})
+		}
+	}()
+	errs := make(chan error, 2)
+	for _, reg := range []uint16{0x0010, 0x0020} {
+		reg := reg
+		go func() {
+			v, err := r.ReadRegister(reg)
+			if err == nil && v != reg {
+				err = errors.Errorf("register %04X = %04X", reg, v)
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMBAPFrameSize(t *testing.T) {
+	if n, err := mbapFrameSize([]byte{0x00, 0x01}); err != nil || n != mbapHeaderSize {
+		t.Fatalf("short head = %d, %v", n, err)
+	}
+	if n, err := mbapFrameSize([]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x05, 0x01}); err != nil || n != 11 {
+		t.Fatalf("frame size = %d, %v", n, err)
+	}
+	if _, err := mbapFrameSize([]byte{0x00, 0x01, 0x00, 0x00, 0xFF, 0xFF, 0x01}); err == nil {
+		t.Fatal("oversized length accepted")
+	}
+}
+
+func TestSetOutputsCoils(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	requests := make(chan []byte, 8)
+	go func() {
+		for {
+			head := make([]byte, 7)
+			if _, err := io.ReadFull(client, head); err != nil {
+				return
+			}
+			rest := make([]byte, 1)
+			if head[1] == FuncWriteMultipleCoils {
+				rest = make([]byte, int(head[6])+2)
+			}
+			if _, err := io.ReadFull(client, rest); err != nil {
+				return
+			}
+			req := append(head, rest...)
+			requests <- req
+			switch {
+			case req[1] == FuncWriteSingleCoil:
+				client.Write(req)
+			case req[3] == 0x04:
+				// 从第 5 路开始的批量写入返回不支持的功能码
+				client.Write(AppendCRC16([]byte{0x01, 0x8F, 0x01}))
+			default:
+				client.Write(AppendCRC16(req[:6:6]))
+			}
+		}
+	}()
+	// 1~3 路一帧写入
+	if err := r.SetOutputs(OutputStates{{Route: 3, Value: 1}, {Route: 1, Value: 1}, {Route: 2, Value: 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; !bytes.Equal(req, WriteCoilsFrame(0x01, 0, []bool{true, false, true})) {
+		t.Fatalf("request % X", req)
+	}
+	// 第 2 路按已知状态补齐，1~4 路一帧写入
+	if err := r.SetOutputs(OutputStates{{Route: 1, Value: 0}, {Route: 4, Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; !bytes.Equal(req, WriteCoilsFrame(0x01, 0, []bool{false, false, true, true})) {
+		t.Fatalf("request % X", req)
+	}
+	// 第 6 路状态未知，分为两段；设备不支持时逐路写入
+	if err := r.SetOutputs(OutputStates{{Route: 5, Value: 1}, {Route: 7, Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range [][]byte{
+		WriteCoilsFrame(0x01, 4, []bool{true}),
+		WriteCoilFrame(0x01, 4, true),
+		WriteCoilFrame(0x01, 6, true),
+	} {
+		if req := <-requests; !bytes.Equal(req, want) {
+			t.Fatalf("request % X, want % X", req, want)
+		}
+	}
+	want := OutputStates{{Route: 1, Value: 0}, {Route: 2, Value: 0}, {Route: 3, Value: 1}, {Route: 4, Value: 1}, {Route: 5, Value: 1}, {Route: 7, Value: 1}}
+	if got := r.OutputState(); !got.Equal(want) {
+		t.Fatalf("output state %v, want %v", got, want)
+	}
+}
+
+func TestVerifyWritesCoils(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU), VerifyWrites(time.Second))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	// 读回的线圈状态，第一次第 3 路未生效
+	coils := make(chan byte, 2)
+	coils <- 0x01
+	coils <- 0x05
+	requests := make(chan []byte, 8)
+	go func() {
+		for {
+			head := make([]byte, 7)
+			if _, err := io.ReadFull(client, head); err != nil {
+				return
+			}
+			rest := make([]byte, 1)
+			if head[1] == FuncWriteMultipleCoils {
+				rest = make([]byte, int(head[6])+2)
+			}
+			if _, err := io.ReadFull(client, rest); err != nil {
+				return
+			}
+			req := append(head, rest...)
+			requests <- req
+			if req[1] == FuncReadCoils {
+				client.Write(AppendCRC16([]byte{0x01, FuncReadCoils, 0x01, <-coils}))
+				continue
+			}
+			client.Write(AppendCRC16(req[:6:6]))
+		}
+	}()
+	states := OutputStates{{Route: 1, Value: 1}, {Route: 3, Value: 1}}
+	if err := r.SetOutputs(states); errors.Cause(err) != ErrNotConfirmed {
+		t.Fatalf("mismatched coils: %v", err)
+	}
+	for _, want := range [][]byte{
+		WriteCoilsFrame(0x01, 0, []bool{true}),
+		WriteCoilsFrame(0x01, 2, []bool{true}),
+		ReadCoilsFrame(0x01, 0, 3),
+	} {
+		if req := <-requests; !bytes.Equal(req, want) {
+			t.Fatalf("request % X, want % X", req, want)
+		}
+	}
+	if got := r.OutputState(); len(got) != 0 {
+		t.Fatalf("unconfirmed output state saved: %v", got)
+	}
+	if err := r.SetOutputs(states); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.OutputState(); !got.Equal(states) {
+		t.Fatalf("output state %v, want %v", got, states)
+	}
+}
+
+func TestAnalogInputs(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Hour), Framing(FramingRTU), AnalogInputs(0x0010, 2))
+	go func() {
+		req := make([]byte, 8)
+		// 上线后的定时读取
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		client.Write(AppendCRC16([]byte{0x01, 0x04, 0x04, 0x00, 0x0C, 0x0F, 0xA0}))
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		client.Write(AppendCRC16([]byte{0x01, 0x04, 0x02, 0x07, 0xD0}))
+	}()
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	deadline := time.Now().Add(time.Second)
+	for len(r.Snapshot().Analog) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("analog inputs not read after init")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	values, err := r.QueryAnalogInputs(ctx, 0x0011, 1)
+	if err != nil || len(values) != 1 || values[0] != 2000 {
+		t.Fatalf("query analog inputs %v, %v", values, err)
+	}
+	want := []AnalogInput{{Register: 0x0010, Value: 12}, {Register: 0x0011, Value: 2000}}
+	if got := r.Snapshot().Analog; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("snapshot analog %v, want %v", got, want)
+	}
+	if _, err := r.QueryAnalogInputs(ctx, 0, 0); err == nil {
+		t.Fatal("zero count accepted")
+	}
+}
+
+func TestWriteAtAddress(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	deviceID := []byte{0x09, 0x2B, 0x0E, 0x01, 0x01, 0x00, 0x00, 0x01, 0x01, 0x02, 'R', '4'}
+	cases := []struct {
+		name string
+		req  []byte
+		resp []byte
+		call func() error
+	}{
+		{"set register", WriteRegisterFrame(0x05, 0x0001, 0x0003), WriteRegisterFrame(0x05, 0x0001, 0x0003), func() error {
+			return r.SetRegisterAt(ctx, 0x05, 0x0001, 0x0003)
+		}},
+		{"set outputs", WriteCoilsFrame(0x07, 0, []bool{true}), AppendCRC16([]byte{0x07, 0x0F, 0x00, 0x00, 0x00, 0x01}), func() error {
+			return r.SetOutputsAt(ctx, 0x07, OutputStates{{Route: 1, Value: 1}})
+		}},
+		{"read register", ReadHoldingRegistersFrame(0x06, 0x0010, 1), AppendCRC16([]byte{0x06, 0x03, 0x02, 0x01, 0x02}), func() error {
+			if v, err := r.ReadRegisterAt(ctx, 0x06, 0x0010); err != nil || v != 0x0102 {
+				return errors.Errorf("value %04X, %v", v, err)
+			}
+			return nil
+		}},
+		{"query analog inputs", ReadInputRegistersFrame(0x08, 0x0020, 2), AppendCRC16([]byte{0x08, 0x04, 0x04, 0x00, 0x0C, 0x0F, 0xA0}), func() error {
+			if v, err := r.QueryAnalogInputsAt(ctx, 0x08, 0x0020, 2); err != nil || len(v) != 2 || v[0] != 12 || v[1] != 4000 {
+				return errors.Errorf("values %v, %v", v, err)
+			}
+			return nil
+		}},
+		{"identify", ReadDeviceIDFrame(0x09, 0x01, objectVendorName), AppendCRC16(deviceID), func() error {
+			if info, err := r.IdentifyAt(ctx, 0x09); err != nil || info.Model != "R4" {
+				return errors.Errorf("info %+v, %v", info, err)
+			}
+			return nil
+		}},
+	}
+	requests := make(chan []byte, len(cases))
+	go func() {
+		for _, c := range cases {
+			req := make([]byte, len(c.req))
+			if _, err := io.ReadFull(client, req); err != nil {
+				return
+			}
+			requests <- req
+			client.Write(c.resp)
+		}
+	}()
+	for _, c := range cases {
+		if err := c.call(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if req := <-requests; !bytes.Equal(req, c.req) {
+			t.Fatalf("%s: request % X, want % X", c.name, req, c.req)
+		}
+	}
+	// 其他地址的结果不计入本继电器的状态
+	if states := r.OutputState(); len(states) != 0 {
+		t.Fatalf("output state of other address saved: %v", states)
+	}
+	if analog := r.Snapshot().Analog; len(analog) != 0 {
+		t.Fatalf("analog inputs of other address saved: %v", analog)
+	}
+}
+
+func TestScanBus(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		req := make([]byte, 8)
+		for {
+			if _, err := io.ReadFull(client, req); err != nil {
+				return
+			}
+			switch req[0] {
+			case 0x03:
+				client.Write(AppendCRC16([]byte{0x03, 0x03, 0x02, 0x00, 0x01}))
+			case 0x05:
+				client.Write(AppendCRC16([]byte{0x05, 0x83, 0x02}))
+			}
+		}
+	}()
+	found, err := ScanBusTimeout(context.Background(), server, FramingRTU, 5*time.Millisecond)
+	if err != nil || len(found) != 2 || found[0] != 3 || found[1] != 5 {
+		t.Fatalf("scan found %v, %v", found, err)
+	}
+	// 扫描结束后连接仍可使用
+	go client.Write([]byte{0x01})
+	b := make([]byte, 1)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := server.Read(b); err != nil {
+		t.Fatalf("conn unusable after scan: %v", err)
+	}
+	if _, err := ScanBus(context.Background(), server, FramingVendor); errors.Cause(err) != ErrNotModbus {
+		t.Fatalf("vendor scan: %v", err)
+	}
+}
+
+func TestDecodeFloat32(t *testing.T) {
+	// 123.456 = 0x42F6E979
+	cases := map[WordOrderMode][2]uint16{
+		WordOrderABCD: {0x42F6, 0xE979},
+		WordOrderCDAB: {0xE979, 0x42F6},
+		WordOrderBADC: {0xF642, 0x79E9},
+		WordOrderDCBA: {0x79E9, 0xF642},
+	}
+	for order, regs := range cases {
+		if got := DecodeUint32(regs, order); got != 0x42F6E979 {
+			t.Errorf("%s uint32 = %08X", order, got)
+		}
+		if got := DecodeFloat32(regs, order); got != 123.456 {
+			t.Errorf("%s float32 = %v", order, got)
+		}
+	}
+	if got := DecodeInt32([2]uint16{0xFFFF, 0xFFFE}, WordOrderABCD); got != -2 {
+		t.Errorf("int32 = %d", got)
+	}
+}
+
+func TestReadFloat32(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU), WordOrder(WordOrderCDAB))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		client.Write(AppendCRC16([]byte{0x01, 0x03, 0x04, 0xE9, 0x79, 0x42, 0xF6}))
+	}()
+	if v, err := r.ReadFloat32(0x0010); err != nil || v != 123.456 {
+		t.Fatalf("read float32 %v, %v", v, err)
+	}
+}
+
+func TestConfigureInput(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU),
+		InputModeRegisters(0x0200, map[InputMode]uint16{InputPullUp: 0x0001, InputPullDown: 0x0002}))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	requests := make(chan []byte, 1)
+	go func() {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		requests <- append([]byte{}, req...)
+		client.Write(req)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.ConfigureInput(ctx, 3, InputPullDown); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; !bytes.Equal(req, WriteRegisterFrame(0x01, 0x0202, 0x0002)) {
+		t.Fatalf("configure request % X", req)
+	}
+	if err := r.ConfigureInput(ctx, 1, InputCounter); errors.Cause(err) != ErrNotSupported {
+		t.Fatalf("unsupported mode: %v", err)
+	}
+	if err := r.ConfigureInput(ctx, 0, InputPullUp); err == nil {
+		t.Fatal("expected invalid route error")
+	}
+	if err := New(nil, nil, 0x01).ConfigureInput(ctx, 1, InputPullUp); errors.Cause(err) != ErrNotSupported {
+		t.Fatalf("unconfigured configure input: %v", err)
+	}
+}
+
+func TestReadCounters(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU), CounterRegisters(0x0300, 4))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	requests := make(chan []byte, 2)
+	go func() {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		requests <- append([]byte{}, req...)
+		client.Write(AppendCRC16([]byte{0x01, 0x03, 0x08, 0xFF, 0xFF, 0xFF, 0xF0, 0x00, 0x00, 0x00, 0x05}))
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		requests <- append([]byte{}, req...)
+		client.Write(AppendCRC16([]byte{0x01, 0x03, 0x04, 0x00, 0x00, 0x00, 0x10}))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	counts, err := r.ReadCounters(ctx, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[2] != 0xFFFFFFF0 || counts[3] != 5 || len(counts) != 2 {
+		t.Fatalf("counts %v", counts)
+	}
+	if req := <-requests; !bytes.Equal(req, ReadHoldingRegistersFrame(0x01, 0x0302, 4)) {
+		t.Fatalf("read request % X", req)
+	}
+	if _, err := r.ReadCounters(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; !bytes.Equal(req, ReadHoldingRegistersFrame(0x01, 0x0302, 2)) {
+		t.Fatalf("read request % X", req)
+	}
+	want := Counters{{Route: 2, Count: 0x10, Delta: 0x20, Wrapped: true}, {Route: 3, Count: 5}}
+	got, ok := r.GetCounters().(Counters)
+	if !ok || len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("counters %+v, want %+v", got, want)
+	}
+	if _, err := r.uniqueStateTypes([]PropertyType{COUNTERS}); err != nil {
+		t.Fatal(err)
+	}
+	if r.LastSeen().IsZero() {
+		t.Fatal("counters read not recorded in LastSeen")
+	}
+	updates := 0
+	for len(r.Events()) > 0 {
+		if e := <-r.Events(); e.Type == EventPropertyUpdate && e.Payload.(Data).PropertyType == COUNTERS {
+			updates++
+		}
+	}
+	if updates != 2 {
+		t.Fatalf("%d counter update events, want 2", updates)
+	}
+	// 最大路数的默认读取不会因路数回绕而无限循环
+	if _, err := New(nil, nil, 0x01, CounterRegisters(0, 255)).ReadCounters(ctx); errors.Cause(err) != ErrNotModbus {
+		t.Fatalf("read 255 counters on vendor framing: %v", err)
+	}
+	if _, err := r.ReadCounters(ctx, 5); err == nil {
+		t.Fatal("expected out of range error")
+	}
+	if _, err := New(nil, nil, 0x01).ReadCounters(ctx); errors.Cause(err) != ErrNotSupported {
+		t.Fatalf("unconfigured read counters: %v", err)
+	}
+	if delta, wrapped := CounterDelta(10, 25); delta != 15 || wrapped {
+		t.Fatalf("delta %d, wrapped %v", delta, wrapped)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x01, KeepAlive(time.Second), Framing(FramingRTU), FaultRegisters(0x0100, 0x0101, 0x0001))
+	if err := r.ReadLoop(0); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	requests := make(chan []byte, 2)
+	go func() {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		requests <- append([]byte{}, req...)
+		client.Write(AppendCRC16([]byte{0x01, 0x03, 0x02, 0x00, 0x43}))
+		if _, err := io.ReadFull(client, req); err != nil {
+			return
+		}
+		requests <- append([]byte{}, req...)
+		client.Write(req)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	faults, err := r.ReadFaults(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !faults.Has(FaultOverTemperature|FaultOverCurrent) || faults.Has(FaultShortCircuit) {
+		t.Fatalf("faults %s", faults)
+	}
+	if s := faults.String(); s != "over-temperature|over-current|bit 6" {
+		t.Fatalf("faults string %q", s)
+	}
+	if req := <-requests; !bytes.Equal(req, ReadHoldingRegistersFrame(0x01, 0x0100, 1)) {
+		t.Fatalf("read request % X", req)
+	}
+	if err := r.ClearFaults(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; !bytes.Equal(req, WriteRegisterFrame(0x01, 0x0101, 0x0001)) {
+		t.Fatalf("clear request % X", req)
+	}
+	if _, err := New(nil, nil, 0x01).ReadFaults(ctx); errors.Cause(err) != ErrNotSupported {
+		t.Fatalf("unconfigured read faults: %v", err)
+	}
+}
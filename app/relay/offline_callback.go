@@ -0,0 +1,46 @@
+package relay
+
+import "time"
+
+// DefaultOfflineCallbackTimeout 默认离线回调超时
+const DefaultOfflineCallbackTimeout = 5 * time.Second
+
+// OfflineCallbackTimeout 离线回调超时配置，默认 DefaultOfflineCallbackTimeout。
+// 离线回调在单独的协程中执行，超过 d 未返回时 Offline 不再等待，回调继续在后台运行；
+// d < 0 时不等待回调返回。回调应尽快返回，耗时操作（如网络请求）应自行控制超时
+func OfflineCallbackTimeout(d time.Duration) Option {
+	return func(r *Relay) {
+		r.offlineTimeout = d
+	}
+}
+
+// 执行离线回调，连接已关闭，回调阻塞时最多等待离线回调超时
+func (r *Relay) runOfflineCallbacks(reason OfflineReason, err error) {
+	if r.OfflineCallbackFn == nil && r.onOffline == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if r.OfflineCallbackFn != nil {
+			r.OfflineCallbackFn(r)
+		}
+		if r.onOffline != nil {
+			r.onOffline(r, reason, err)
+		}
+	}()
+	timeout := r.offlineTimeout
+	if timeout == 0 {
+		timeout = DefaultOfflineCallbackTimeout
+	}
+	if timeout < 0 {
+		return
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		r.log().Printf("设备 %d 离线回调 %s 内未返回，不再等待", r.SubDeviceID, timeout)
+	}
+}
@@ -0,0 +1,54 @@
+package relay
+
+import (
+	"sync/atomic"
+)
+
+// OfflineReason 下线原因
+type OfflineReason int
+
+const (
+	// OfflineExplicit 调用 Offline 主动下线
+	OfflineExplicit OfflineReason = iota
+	// OfflineContext ctx 取消
+	OfflineContext
+	// OfflineConnError 连接读取失败，配置了 Reconnect 时为重连失败
+	OfflineConnError
+	// OfflineHeartbeat 心跳超时
+	OfflineHeartbeat
+	// OfflineWriteFailures 总线上或不持有连接的继电器连续写入失败达到 MaxWriteFailures
+	OfflineWriteFailures
+)
+
+func (reason OfflineReason) String() string {
+	switch reason {
+	case OfflineExplicit:
+		return "explicit"
+	case OfflineContext:
+		return "context"
+	case OfflineConnError:
+		return "conn error"
+	case OfflineHeartbeat:
+		return "heartbeat timeout"
+	case OfflineWriteFailures:
+		return "write failures"
+	}
+	return "unknown"
+}
+
+// OfflineReasonCallback 带原因的离线回调配置，err 为导致下线的错误，主动下线和 ctx 取消时为 ctx 的错误或 nil。
+// 与 OfflineCallback 可同时配置，先执行 OfflineCallbackFn
+func OfflineReasonCallback(cb func(r *Relay, reason OfflineReason, err error)) Option {
+	return func(r *Relay) {
+		r.onOffline = cb
+	}
+}
+
+// 因 reason 下线，只有第一次生效。
+// 不使用 sync.Once：下线过程中关灯写入失败、离线回调等可能再次触发下线，sync.Once 会死锁
+func (r *Relay) offlineWith(reason OfflineReason, err error) {
+	if !atomic.CompareAndSwapInt32(&r.offlining, 0, 1) {
+		return
+	}
+	r.offline(reason, err)
+}
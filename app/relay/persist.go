@@ -0,0 +1,123 @@
+package relay
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// StateStore 状态存储，保存继电器的状态快照，用于崩溃后恢复最近状态或审计
+type StateStore interface {
+	Save(id uint16, snap Snapshot) error
+}
+
+// PersistTo 持久化配置，每次保存的属性与上一次不同时将状态快照写入 store，
+// 不经过中间件，也不依赖上报、Get* 或 Data，可记录每一次状态变化。
+// 写入方式与 PersistMiddleware 相同，store 按 SubDeviceID 保存
+func PersistTo(store StateStore) Option {
+	return func(r *Relay) {
+		r.persister = newPersister(store)
+	}
+}
+
+// 保存属性后写入快照，属性与上一次相同时跳过
+func (r *Relay) persist(seen bool, old, new Property) {
+	if r.persister == nil || seen && propertyEqual(old, new) {
+		return
+	}
+	r.persister.enqueue(r, r.Snapshot())
+}
+
+// PersistMiddleware 持久化中间件，同一设备某属性与上一次不同时将状态快照写入 store，数据照常通过。
+// 中间件只在发送属性（上报、Get* 或 Data）时执行，两次发送之间的变化不会写入；
+// 需要记录每一次变化时使用 PersistTo。
+// 写入在后台协程中进行，不阻塞读取与上报；写入较慢时同一继电器只保留最新的快照，
+// 写入失败时错误发送到该继电器的 Errors。
+// 与 Dedup 等中间件一样按 SubDeviceID 判断变化，store 也按 SubDeviceID 保存，
+// 多个网关上有相同 SubDeviceID 的继电器应使用不同的中间件与 store
+func PersistMiddleware(store StateStore) Middleware {
+	p := newPersister(store)
+	return func(r *Relay, data Data) (Data, bool) {
+		key := middlewareKey{r.SubDeviceID, data.PropertyType}
+		p.mu.Lock()
+		prev, ok := p.last[key]
+		changed := !ok || !propertyEqual(prev, data.Data)
+		p.last[key] = data.Data
+		p.mu.Unlock()
+		if changed {
+			p.enqueue(r, r.Snapshot())
+		}
+		return data, true
+	}
+}
+
+// 后台写入状态快照
+type persister struct {
+	store   StateStore
+	mu      sync.Mutex
+	last    map[middlewareKey]interface{}
+	pending map[*Relay]Snapshot
+	running bool
+}
+
+func newPersister(store StateStore) *persister {
+	return &persister{store: store, last: map[middlewareKey]interface{}{}, pending: map[*Relay]Snapshot{}}
+}
+
+// 放入待写入快照，覆盖同一继电器未写入的快照，没有写入协程时启动
+func (p *persister) enqueue(r *Relay, snap Snapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending[r] = snap
+	if !p.running {
+		p.running = true
+		go p.flush()
+	}
+}
+
+// 写入全部待写入快照，没有时退出
+func (p *persister) flush() {
+	for {
+		p.mu.Lock()
+		if len(p.pending) == 0 {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+		batch := p.pending
+		p.pending = map[*Relay]Snapshot{}
+		p.mu.Unlock()
+		for r, snap := range batch {
+			if err := p.store.Save(r.SubDeviceID, snap); err != nil {
+				r.reportError(errors.Wrap(err, "persist state failed"))
+			}
+		}
+	}
+}
+
+// MemoryStore 内存状态存储，保存每个设备最近的快照
+type MemoryStore struct {
+	mu    sync.RWMutex
+	snaps map[uint16]Snapshot
+}
+
+// NewMemoryStore 创建内存状态存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{snaps: map[uint16]Snapshot{}}
+}
+
+// Save 保存快照
+func (s *MemoryStore) Save(id uint16, snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snaps[id] = snap
+	return nil
+}
+
+// Load 读取设备最近的快照
+func (s *MemoryStore) Load(id uint16) (Snapshot, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.snaps[id]
+	return snap, ok
+}
@@ -0,0 +1,191 @@
+package relay
+
+import (
+	"context"
+	"iot-sdk-go/sdk/device"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPoolClosed 连接池已关闭
+var ErrPoolClosed = errors.New("pool closed")
+
+// Pool 连接池，限制到同一网关的连接数（包括借出和空闲的连接），并复用已归还的连接。
+//
+// 借用周期：Get 借出连接后传给 New（或使用 Pool.Relay），继电器 Offline 时关闭连接即归还到池中；
+// 读写出错（超时除外）的连接归还时直接关闭，不再复用。配合 Reconnect 使用时，
+// dial 可以写成 func() (net.Conn, error) { return pool.Get(ctx) }，旧连接在重连前归还。
+// 挂在 Bus 上的继电器不关闭共享连接，需由调用方在 Bus 不再使用后关闭连接归还。
+type Pool struct {
+	dial  func() (net.Conn, error)
+	slots chan struct{} // 借出的连接数
+
+	mu     sync.Mutex
+	idle   []net.Conn
+	closed bool
+}
+
+// NewPool 创建连接池，max 为最大连接数，max <= 0 时为 1
+func NewPool(dial func() (net.Conn, error), max int) *Pool {
+	if max <= 0 {
+		max = 1
+	}
+	return &Pool{dial: dial, slots: make(chan struct{}, max)}
+}
+
+// Get 借出一个连接，优先复用空闲连接；连接数已满时等待归还，直到 ctx 结束
+func (p *Pool) Get(ctx context.Context) (net.Conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "get conn failed")
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, errors.Wrap(ErrPoolClosed, "get conn failed")
+	}
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return &pooledConn{Conn: conn, pool: p}, nil
+	}
+	p.mu.Unlock()
+	conn, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, errors.Wrap(err, "get conn failed")
+	}
+	return &pooledConn{Conn: conn, pool: p}, nil
+}
+
+// Relay 借出连接并创建继电器，继电器下线时归还连接
+func (p *Pool) Relay(ctx context.Context, DeviceInstance *device.Device, subDeviceID uint16, options ...Option) (*Relay, error) {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return New(DeviceInstance, conn, subDeviceID, options...), nil
+}
+
+// Idle 空闲连接数
+func (p *Pool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
+}
+
+// Close 关闭连接池和所有空闲连接，借出的连接归还时关闭
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, conn := range idle {
+		conn.Close()
+	}
+	return nil
+}
+
+// 归还连接，连接已损坏或连接池已关闭时关闭连接
+func (p *Pool) put(conn net.Conn, broken bool) {
+	p.mu.Lock()
+	if broken || p.closed {
+		p.mu.Unlock()
+		conn.Close()
+	} else {
+		p.idle = append(p.idle, conn)
+		p.mu.Unlock()
+	}
+	<-p.slots
+}
+
+// 借出的连接，Close 时归还到连接池
+type pooledConn struct {
+	net.Conn
+	pool *Pool
+
+	closed int32        // 已归还，原子操作
+	broken int32        // 读写出错，原子操作
+	ops    sync.RWMutex // 读写持读锁，归还时等待进行中的读写退出
+	dmu    sync.Mutex   // 保护设置超时，避免归还时设置的超时被覆盖
+}
+
+var errConnReturned = errors.New("conn returned to pool")
+
+func (c *pooledConn) Read(b []byte) (int, error) {
+	c.ops.RLock()
+	defer c.ops.RUnlock()
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return 0, errConnReturned
+	}
+	n, err := c.Conn.Read(b)
+	c.check(err)
+	return n, err
+}
+
+func (c *pooledConn) Write(b []byte) (int, error) {
+	c.ops.RLock()
+	defer c.ops.RUnlock()
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return 0, errConnReturned
+	}
+	n, err := c.Conn.Write(b)
+	c.check(err)
+	return n, err
+}
+
+func (c *pooledConn) SetDeadline(t time.Time) error {
+	return c.setDeadline(c.Conn.SetDeadline, t)
+}
+
+func (c *pooledConn) SetReadDeadline(t time.Time) error {
+	return c.setDeadline(c.Conn.SetReadDeadline, t)
+}
+
+func (c *pooledConn) SetWriteDeadline(t time.Time) error {
+	return c.setDeadline(c.Conn.SetWriteDeadline, t)
+}
+
+func (c *pooledConn) setDeadline(set func(time.Time) error, t time.Time) error {
+	c.dmu.Lock()
+	defer c.dmu.Unlock()
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return errConnReturned
+	}
+	return set(t)
+}
+
+// Close 归还连接：打断进行中的读写，等待其退出后清除超时再放回连接池，可重复调用
+func (c *pooledConn) Close() error {
+	c.dmu.Lock()
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		c.dmu.Unlock()
+		return nil
+	}
+	c.Conn.SetDeadline(time.Now())
+	c.dmu.Unlock()
+	c.ops.Lock()
+	defer c.ops.Unlock()
+	broken := atomic.LoadInt32(&c.broken) == 1
+	if !broken && c.Conn.SetDeadline(time.Time{}) != nil {
+		broken = true
+	}
+	c.pool.put(c.Conn, broken)
+	return nil
+}
+
+// 读写出错（归还打断与超时除外）时标记为损坏
+func (c *pooledConn) check(err error) {
+	if err == nil || atomic.LoadInt32(&c.closed) == 1 || isTimeout(err) {
+		return
+	}
+	atomic.StoreInt32(&c.broken, 1)
+}
@@ -0,0 +1,70 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestPool(t *testing.T) {
+	dials := 0
+	var peers []net.Conn
+	p := NewPool(func() (net.Conn, error) {
+		dials++
+		client, server := net.Pipe()
+		peers = append(peers, client)
+		return server, nil
+	}, 1)
+	defer func() {
+		for _, c := range peers {
+			c.Close()
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	r, err := p.Relay(ctx, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	short, cancelShort := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelShort()
+	if _, err := p.Get(short); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("Get over max: %v", err)
+	}
+
+	r.Offline()
+	r.Wait()
+	if p.Idle() != 1 {
+		t.Fatalf("Idle = %d, want 1", p.Idle())
+	}
+	conn, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dials != 1 {
+		t.Fatalf("dials = %d, want 1", dials)
+	}
+	go peers[0].Write([]byte{0x01})
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("reused conn: %v", err)
+	}
+
+	peers[0].Close()
+	conn.Read(make([]byte, 1))
+	conn.Close()
+	if p.Idle() != 0 {
+		t.Fatal("broken conn returned to pool")
+	}
+
+	p.Close()
+	if _, err := p.Get(ctx); errors.Cause(err) != ErrPoolClosed {
+		t.Fatalf("Get after Close: %v", err)
+	}
+}
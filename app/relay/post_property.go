@@ -1,8 +1,11 @@
 package relay
 
 import (
+	"context"
 	"iot-sdk-go/sdk/device"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // PropertyIDs 继电器属性 ID 列表
@@ -11,6 +14,7 @@ type PropertyIDs struct {
 	InputStateID  uint16
 	TemperatureID uint16
 	HumidityID    uint16
+	CounterID     uint16
 }
 
 var relayPropertyIDs = PropertyIDs{
@@ -18,41 +22,146 @@ var relayPropertyIDs = PropertyIDs{
 	InputStateID:  2,
 	TemperatureID: 3,
 	HumidityID:    4,
+	CounterID:     5,
 }
 
-// AutoPostProperty 自动发送属性，会阻塞主线程
-func (r *Relay) AutoPostProperty(stateTypes []PropertyType) {
-	fns := GetPropertyFnMap{
-		OUTPUTSTATE: r.GetOutputState,
-		INPUTSTATE:  r.GetInputState,
-		TH:          r.GetTH,
+// PropertySink 属性接收方法配置，每次定时发送的属性（经过中间件，未被丢弃）都会传给 fn，
+// fn 在发送协程中调用，阻塞时属性在发送队列中积压，按 PostQueue 的策略处理。
+// 同时发送到 Instance，Instance 为 nil 时只发送给 fn
+func PropertySink(fn func(PropertyType, Property)) Option {
+	return func(r *Relay) {
+		r.sink = fn
+	}
+}
+
+// AutoPostProperty 开启协程定时发送属性，重复的属性类型只发送一次，包含未知属性类型时返回错误。
+// 开始时等待定时询问的首次响应（最长 1 秒）后立即发送一次已收到的属性，之后按 KeepAlive 间隔发送
+func (r *Relay) AutoPostProperty(stateTypes []PropertyType) error {
+	stateTypes, err := r.uniqueStateTypes(stateTypes)
+	if err != nil {
+		return errors.Wrap(err, "auto post property failed")
+	}
+	fns := GetPropertyFnMap{}
+	for t, fn := range r.getters {
+		fns[t] = fn
 	}
-	go r.postPropertyLoop(fns, stateTypes)
+	queue := make(chan postItem, r.postQueue.size)
+	r.goLoop(func() {
+		r.postPropertyLoop(fns, stateTypes, queue)
+	})
+	r.goLoop(func() {
+		r.postSender(queue)
+	})
+	return nil
 }
 
-// 循环发送属性
-func (r *Relay) postPropertyLoop(fns GetPropertyFnMap, propertyTypes []PropertyType) {
+// 上线后等待首次询问响应的时间上限
+const initialPostTimeout = time.Second
+
+// 循环发送属性，开始时先立即发送一次
+func (r *Relay) postPropertyLoop(fns GetPropertyFnMap, propertyTypes []PropertyType, queue chan postItem) {
+	r.postInitial(fns, propertyTypes, queue)
 	for {
+		retick := r.retickChan()
+		timer := r.clock.NewTimer(r.keepAliveInterval())
 		select {
 		case <-r.closed:
+			timer.Stop()
+			return
+		case <-r.ctx.Done():
+			timer.Stop()
 			return
-		default:
-			time.Sleep(r.keepAlive)
-			for _, name := range propertyTypes {
-				if _, ok := fns[name]; !ok {
-					continue
-				}
-				property := fns[name]()
-				switch name {
-				case OUTPUTSTATE:
-					r.postOutputState(property)
-				case INPUTSTATE:
-					r.postInputState(property)
-				case TH:
-					r.postTH(property)
-				}
+		case <-retick:
+			timer.Stop()
+		case <-timer.C():
+			r.postProperties(fns, propertyTypes, queue)
+		}
+	}
+}
+
+// 等待上线时的首次询问响应后立即发送一次，只发送已收到的属性；
+// 超时未响应时只记录日志，留给定时发送
+func (r *Relay) postInitial(fns GetPropertyFnMap, propertyTypes []PropertyType, queue chan postItem) {
+	ctx, cancel := context.WithTimeout(r.ctx, initialPostTimeout)
+	defer cancel()
+	var received []PropertyType
+	for _, t := range propertyTypes {
+		if r.inquired(t) {
+			if err := r.awaitFirst(ctx, t); err != nil {
+				r.log().Printf("设备 %d 上线后未收到 %s: %v", r.SubDeviceID, t, err)
 			}
 		}
+		if r.received(t) {
+			received = append(received, t)
+		}
+	}
+	r.postProperties(fns, received, queue)
+}
+
+// 是否定时询问该属性
+func (r *Relay) inquired(t PropertyType) bool {
+	return r.framing == FramingVendor && !r.passive && (t == TH || t == INPUTSTATE)
+}
+
+// 是否已收到过该属性
+func (r *Relay) received(t PropertyType) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.seen[t]
+}
+
+// 等待第一次收到该属性，已收到时立即返回
+func (r *Relay) awaitFirst(ctx context.Context, t PropertyType) error {
+	ch := r.waiters.add(t)
+	defer r.waiters.remove(t, ch)
+	if r.received(t) {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.closed:
+		return ErrRelayClosed
+	}
+}
+
+// 获取一次属性放入发送队列
+func (r *Relay) postProperties(fns GetPropertyFnMap, propertyTypes []PropertyType, queue chan postItem) {
+	for _, name := range propertyTypes {
+		if _, ok := fns[name]; !ok {
+			continue
+		}
+		property := fns[name]()
+		if property == nil {
+			continue
+		}
+		r.enqueuePost(queue, postItem{name, property})
+	}
+}
+
+// 发送属性到 PropertySink 和 Instance，配置了 PropertyEncoder 时按其编码发送
+func (r *Relay) post(item postItem) {
+	if r.sink != nil {
+		r.sink(item.t, item.property)
+	}
+	if r.Instance == nil {
+		return
+	}
+	if r.encoder != nil {
+		r.postEncoded(item)
+		return
+	}
+	switch item.t {
+	case OUTPUTSTATE:
+		r.postOutputState(item.property)
+	case INPUTSTATE:
+		r.postInputState(item.property)
+	case TH:
+		r.postTH(item.property)
+	case COUNTERS:
+		r.postCounters(item.property)
 	}
 }
 
@@ -85,8 +194,20 @@ func (r *Relay) postTH(property interface{}) {
 	}
 }
 
-// PostProperty 发送属性
+// 发送脉冲计数
+func (r *Relay) postCounters(property Property) {
+	if counters, ok := property.(Counters); ok {
+		for _, c := range counters {
+			r.PostProperty(relayPropertyIDs.CounterID, []interface{}{c.Route, c.Count})
+		}
+	}
+}
+
+// PostProperty 发送属性，Instance 为 nil 时忽略
 func (r *Relay) PostProperty(id uint16, value []interface{}) {
+	if r.Instance == nil {
+		return
+	}
 	p := device.Property{
 		SubDeviceID: r.SubDeviceID,
 		PropertyID:  id,
@@ -0,0 +1,85 @@
+package relay
+
+// 默认发送队列长度
+const defaultPostQueueSize = 64
+
+// OverflowPolicy 发送队列满时的处理策略
+type OverflowPolicy int
+
+const (
+	// Block 等待队列有空位，定时获取属性随之推迟（默认）
+	Block OverflowPolicy = iota
+	// DropOldest 丢弃队列中最早的属性，放入新属性
+	DropOldest
+	// DropNewest 丢弃新属性
+	DropNewest
+)
+
+// 属性发送队列配置
+type postQueue struct {
+	size   int
+	policy OverflowPolicy
+}
+
+// 待发送的属性
+type postItem struct {
+	t        PropertyType
+	property Property
+}
+
+// PostQueue 属性发送队列配置，默认长度 64、策略 Block。
+// 定时获取的属性先放入队列，由单独的协程发送到 PropertySink 和 Instance，
+// 平台不可达、发送变慢时队列长度即积压上限，丢弃的属性数记录在 Stats 的 PostsDropped 中
+func PostQueue(size int, policy OverflowPolicy) Option {
+	return func(r *Relay) {
+		if size < 1 {
+			size = 1
+		}
+		r.postQueue = postQueue{size: size, policy: policy}
+	}
+}
+
+// 按策略放入发送队列
+func (r *Relay) enqueuePost(queue chan postItem, item postItem) {
+	switch r.postQueue.policy {
+	case DropNewest:
+		select {
+		case queue <- item:
+		default:
+			count(&r.counters.postsDropped)
+		}
+	case DropOldest:
+		for {
+			select {
+			case queue <- item:
+				return
+			default:
+			}
+			select {
+			case <-queue:
+				count(&r.counters.postsDropped)
+			default:
+			}
+		}
+	default:
+		select {
+		case queue <- item:
+		case <-r.closed:
+		case <-r.ctx.Done():
+		}
+	}
+}
+
+// 发送协程，下线后队列中未发送的属性丢弃
+func (r *Relay) postSender(queue chan postItem) {
+	for {
+		select {
+		case item := <-queue:
+			r.post(item)
+		case <-r.closed:
+			return
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package relay
+
+import (
+	"github.com/pkg/errors"
+)
+
+// RegisterProperty 注册属性获取方法，可覆盖内置的 OUTPUTSTATE、INPUTSTATE、TH。
+// 注册的属性类型可用于 GetProperty 与 Online，只能在 New 中使用。
+// 默认编码只发送内置属性，Online、AutoPostProperty 使用新的属性类型时需同时配置 PropertySink 或 PropertyEncoder
+func RegisterProperty(t PropertyType, fn GetPropertyFn) Option {
+	return func(r *Relay) {
+		r.getters[t] = fn
+	}
+}
+
+// GetProperty 按属性类型调用注册的获取方法，未注册时返回错误
+func (r *Relay) GetProperty(t PropertyType) (Property, error) {
+	fn, ok := r.getters[t]
+	if !ok {
+		return nil, errors.Errorf("get property failed: unknown property type %q", t)
+	}
+	return fn(), nil
+}
@@ -0,0 +1,66 @@
+package relay
+
+import (
+	"reflect"
+)
+
+// 回调队列长度
+const callbackQueueSize = 64
+
+// OnPropertyChange 属性变化回调配置。
+// 读取循环发现属性值与上一次不同时触发，上线后的第一次读取视为从零值变化；
+// 回调在独立协程中按顺序执行，不会阻塞读取循环，队列满时丢弃。
+func OnPropertyChange(cb func(r *Relay, t PropertyType, old, new Property)) Option {
+	return func(r *Relay) {
+		r.onPropertyChange = cb
+	}
+}
+
+// 属性变化时通知回调
+func (r *Relay) propertyChanged(t PropertyType, seen bool, old, new Property) {
+	if r.onPropertyChange == nil {
+		return
+	}
+	if seen && propertyEqual(old, new) {
+		return
+	}
+	r.dispatch(func() {
+		r.onPropertyChange(r, t, old, new)
+	})
+}
+
+// 属性值是否相同，输出、输入状态按路数比较，与顺序无关
+func propertyEqual(old, new Property) bool {
+	switch value := new.(type) {
+	case OutputStates:
+		prev, ok := old.(OutputStates)
+		return ok && prev.Equal(value)
+	case InputStates:
+		prev, ok := old.(InputStates)
+		return ok && prev.Equal(value)
+	}
+	return reflect.DeepEqual(old, new)
+}
+
+// 将回调放入队列，队列满时丢弃
+func (r *Relay) dispatch(fn func()) {
+	select {
+	case r.callbacks <- fn:
+	default:
+		r.log().Printf("设备 %d 回调队列已满，丢弃回调", r.SubDeviceID)
+	}
+}
+
+// 顺序执行回调
+func (r *Relay) callbackLoop() {
+	for {
+		select {
+		case fn := <-r.callbacks:
+			fn()
+		case <-r.closed:
+			return
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package relay
+
+import (
+	"iot-sdk-go/sdk/device"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// PropertyEncoder 属性编码配置，定时发送到 Instance 的属性由 fn 编码，替代默认编码。
+// 返回值的 key 为属性 ID（十进制字符串），value 为 []interface{} 时作为属性值发送，
+// 其他类型包装为单个元素的 []interface{}；返回 nil 时不发送；fn 返回错误或 key 不是合法的属性 ID 时
+// 本次不发送并报告错误。未配置时使用默认编码：输出、输入状态和脉冲计数按路发送 [路号, 值]，温湿度分别发送
+func PropertyEncoder(fn func(PropertyType, Property) (map[string]interface{}, error)) Option {
+	return func(r *Relay) {
+		r.encoder = fn
+	}
+}
+
+// 按 PropertyEncoder 编码并发送
+func (r *Relay) postEncoded(item postItem) {
+	properties, err := r.encodeProperty(item.t, item.property)
+	if err != nil {
+		r.log().Printf("设备 %d %v", r.SubDeviceID, err)
+		r.reportError(err)
+		return
+	}
+	for _, p := range properties {
+		r.Instance.PostProperty(p)
+	}
+}
+
+// 调用 PropertyEncoder 编码属性，按属性 ID 排序
+func (r *Relay) encodeProperty(t PropertyType, property Property) ([]device.Property, error) {
+	values, err := r.encoder(t, property)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encode %s failed", t)
+	}
+	properties := make([]device.Property, 0, len(values))
+	for key, value := range values {
+		id, err := strconv.ParseUint(key, 10, 16)
+		if err != nil {
+			return nil, errors.Errorf("encode %s failed: invalid property id %q", t, key)
+		}
+		v, ok := value.([]interface{})
+		if !ok {
+			v = []interface{}{value}
+		}
+		properties = append(properties, device.Property{
+			SubDeviceID: r.SubDeviceID,
+			PropertyID:  uint16(id),
+			Value:       v,
+		})
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		return properties[i].PropertyID < properties[j].PropertyID
+	})
+	return properties, nil
+}
@@ -0,0 +1,52 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPulseInProgress 该路正在脉冲输出
+var ErrPulseInProgress = errors.New("pulse in progress")
+
+// PulseOutput 脉冲输出，将该路设置为 value，持续 d 后恢复为相反的值。
+// 同一路同时只允许一个脉冲，否则返回 ErrPulseInProgress；
+// 首次写入失败时直接返回错误，不会恢复；下线或 ctx 取消时不再恢复。
+func (r *Relay) PulseOutput(route uint8, value uint8, d time.Duration) error {
+	if err := checkOutput(route, value); err != nil {
+		return errors.Wrap(err, "pulse output failed")
+	}
+	r.pulseMu.Lock()
+	if r.pulsing[route] {
+		r.pulseMu.Unlock()
+		return ErrPulseInProgress
+	}
+	r.pulsing[route] = true
+	r.pulseMu.Unlock()
+
+	if err := r.SetOutput(route, value); err != nil {
+		r.endPulse(route)
+		return errors.Wrap(err, "pulse output failed")
+	}
+	r.goLoop(func() {
+		defer r.endPulse(route)
+		timer := r.clock.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			if err := r.SetOutput(route, 1-value); err != nil {
+				r.log().Printf("设备 %d 第 %d 路脉冲恢复失败: %v", r.SubDeviceID, route, err)
+			}
+		case <-r.closed:
+		case <-r.ctx.Done():
+		}
+	})
+	return nil
+}
+
+// 结束脉冲
+func (r *Relay) endPulse(route uint8) {
+	r.pulseMu.Lock()
+	delete(r.pulsing, route)
+	r.pulseMu.Unlock()
+}
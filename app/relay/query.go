@@ -0,0 +1,109 @@
+package relay
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// 查询命令
+const (
+	inquiryTHCommand    = "A0 01 08 2A 00 00 00 00 00 00 00 00 A7"
+	inquiryInputCommand = "A0 01 08 1B 00 00 00 00 00 00 00 00 A7"
+)
+
+// 等待响应的查询
+type waiters struct {
+	mu sync.Mutex
+	m  map[PropertyType][]chan Property
+}
+
+// QueryOutputState 等待下一帧输出状态并返回，ctx 控制等待时间。
+// 设备协议没有输出状态查询命令，输出状态由设置命令的回复或设备主动上报，
+// 因此这里不发送命令，只等待下一帧输出状态。
+func (r *Relay) QueryOutputState(ctx context.Context) (OutputStates, error) {
+	value, err := r.query(ctx, OUTPUTSTATE, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "query output state failed")
+	}
+	return append(OutputStates{}, value.(OutputStates)...), nil
+}
+
+// QueryInputState 发送查询输入状态命令并等待响应，ctx 控制等待时间
+func (r *Relay) QueryInputState(ctx context.Context) (InputStates, error) {
+	value, err := r.query(ctx, INPUTSTATE, inquiryInputCommand)
+	if err != nil {
+		return nil, errors.Wrap(err, "query input state failed")
+	}
+	return append(InputStates{}, value.(InputStates)...), nil
+}
+
+// QueryTH 发送查询温湿度命令并等待响应，ctx 控制等待时间
+func (r *Relay) QueryTH(ctx context.Context) (TemperatureAndHumidity, error) {
+	value, err := r.query(ctx, TH, inquiryTHCommand)
+	if err != nil {
+		return TemperatureAndHumidity{}, errors.Wrap(err, "query th failed")
+	}
+	return value.(TemperatureAndHumidity), nil
+}
+
+// 发送命令并等待对应属性类型的下一帧，协议没有请求编号，按命令码对应的属性类型匹配
+func (r *Relay) query(ctx context.Context, t PropertyType, cmd string) (Property, error) {
+	if isClosed(r.closed) {
+		return nil, ErrRelayClosed
+	}
+	ch := r.waiters.add(t)
+	defer r.waiters.remove(t, ch)
+	// 同类查询未响应时不重复发送，等待其响应即可
+	if cmd != "" {
+		if err := r.inquire(ctx, t, cmd); err != nil {
+			return nil, err
+		}
+	}
+	select {
+	case value := <-ch:
+		return value, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-r.closed:
+		return nil, ErrRelayClosed
+	}
+}
+
+// 注册等待
+func (w *waiters) add(t PropertyType) chan Property {
+	ch := make(chan Property, 1)
+	w.mu.Lock()
+	if w.m == nil {
+		w.m = make(map[PropertyType][]chan Property)
+	}
+	w.m[t] = append(w.m[t], ch)
+	w.mu.Unlock()
+	return ch
+}
+
+// 取消等待
+func (w *waiters) remove(t PropertyType, ch chan Property) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list := w.m[t]
+	for i := range list {
+		if list[i] == ch {
+			w.m[t] = append(list[:i:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// 通知所有等待该属性类型的查询
+func (w *waiters) notify(t PropertyType, value Property) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, ch := range w.m[t] {
+		select {
+		case ch <- value:
+		default:
+		}
+	}
+}
@@ -1,57 +1,112 @@
 package relay
 
 import (
-	"fmt"
 	"io"
-	"relay/pkg/utils"
-	"strings"
+	"net"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-// ReadLoop 开启一个协程，从连接中循环读取数据
+// 解析帧需要的最小长度：帧头、设备号、命令、5 字节数据、帧尾
+const minFrameLength = 10
+
+// ReadTimeout 读超时配置，默认不超时。
+// 每读取一帧前设置读超时，超时后丢弃已读到的不完整数据；
+// 配置了 HeartbeatTimeout 且已超过心跳超时则按读取失败处理（重连或下线），否则继续读取。
+func ReadTimeout(d time.Duration) Option {
+	return func(r *Relay) {
+		r.readTimeout = d
+	}
+}
+
+// ReadLoop 开启一个协程，从连接中循环读取数据帧，帧校验失败时逐字节重新对齐。
+// byteOrderLen 为帧长，配置了 LengthPrefix 时为最大帧长
 func (r *Relay) ReadLoop(byteOrderLen int) error {
-	if r.Conn == nil {
-		return errors.Wrap(errors.New("not connected"), "read data failed")
+	if r.conn() == nil {
+		return errors.Wrap(ErrNotConnected, "read data failed")
+	}
+	if r.framing == FramingVendor && byteOrderLen < minFrameLength {
+		return errors.Errorf("read data failed: frame length %d less than %d", byteOrderLen, minFrameLength)
+	}
+	if r.framing == FramingVendor && r.lengthPrefix != nil {
+		if err := r.lengthPrefix.validate(); err != nil {
+			return errors.Wrap(err, "read data failed")
+		}
 	}
-	go func() {
+	r.goLoop(func() {
+		reader := r.newReader(byteOrderLen)
 		for {
-			data := make([]byte, byteOrderLen)
-			if _, err := r.Conn.Read(data); err != nil {
-				fmt.Printf("%v %v \n", time.Now().Format("2006-01-02 15:04:05"), err)
-				if err == io.EOF {
+			conn := r.conn()
+			if r.readTimeout > 0 {
+				conn.SetReadDeadline(time.Now().Add(r.readTimeout))
+			}
+			data, skipped, err := reader.next(conn)
+			if skipped > 0 {
+				r.log().Printf("设备 %d 重新对齐，丢弃 %d 字节", r.SubDeviceID, skipped)
+			}
+			if _, ok := err.(*frameError); ok {
+				r.log().Printf("设备 %d 丢弃错误帧: %v", r.SubDeviceID, err)
+				count(&r.counters.crcFailures)
+				r.reportError(err)
+				continue
+			}
+			if err != nil {
+				reader.reset()
+				// 已下线，连接已关闭；不持有连接时恢复读超时
+				if isClosed(r.closed) {
+					if !r.ownConn {
+						conn.SetReadDeadline(time.Time{})
+					}
+					break
+				}
+				if isTimeout(err) && r.alive() {
+					continue
+				}
+				r.log().Printf("设备 %d 读取失败: %v", r.SubDeviceID, err)
+				if r.dial != nil && r.reconnect() == nil {
 					continue
 				}
-				r.Offline()
+				if err == io.EOF && r.dial == nil {
+					continue
+				}
+				err = errors.Wrap(err, "read data failed")
+				r.reportError(err)
+				r.offlineWith(OfflineConnError, err)
 				break
 			}
-			cmd, err := cmdToStringLower(data[3])
-			if err != nil {
-				// TODO log
-				continue
-			}
-			r.dispatchSaveTask(cmd, data)
+			r.handleFrame(data)
 		}
-	}()
+	})
 	return nil
 }
 
-func cmdToStringLower(b byte) (string, error) {
-	cmd, err := utils.ByteToHex(b)
+// 处理一帧已校验的数据
+func (r *Relay) handleFrame(frame []byte) {
+	r.touch()
+	count(&r.counters.framesRead)
+	r.checkSequence(frame)
+	if r.recorder != nil {
+		if err := r.recorder.record(r.now(), frame); err != nil {
+			r.reportError(errors.Wrap(err, "record frame failed"))
+		}
+	}
+	if r.framing != FramingVendor {
+		r.deliver(frame)
+		return
+	}
+	data, err := r.decoder.Decode(frame)
 	if err != nil {
-		return "", err
+		r.reportError(err)
+		return
 	}
-	return strings.ToLower(cmd), nil
+	data.Raw = append([]byte{}, frame...)
+	data.Time = r.now()
+	r.store(data)
 }
 
-func (r *Relay) dispatchSaveTask(cmd string, data []byte) {
-	switch cmd {
-	case "aa":
-		r.SaveOutputState(data)
-	case "1b":
-		r.SaveInputState(data)
-	case "2a":
-		r.SaveTH(data)
-	}
+// 是否超时错误
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
 }
@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 重连退避间隔上限
+const maxReconnectBackoff = time.Minute
+
+// Reconnect 断线重连配置。
+// 读取出错时使用 dial 重新建立连接，退避间隔从 backoff 开始指数增长，最长 1 分钟；
+// 连续 maxRetries 次失败后才下线并触发 OfflineCallbackFn，maxRetries <= 0 时不限次数。
+func Reconnect(dial func() (net.Conn, error), backoff time.Duration, maxRetries int) Option {
+	return func(r *Relay) {
+		r.dial = dial
+		r.reconnectBackoff = backoff
+		r.maxRetries = maxRetries
+	}
+}
+
+// 当前连接
+func (r *Relay) conn() net.Conn {
+	r.connMu.RLock()
+	defer r.connMu.RUnlock()
+	return r.Conn
+}
+
+// 关闭旧连接并重新拨号，成功后替换 Conn
+func (r *Relay) reconnect() error {
+	r.conn().Close()
+	delay := r.reconnectBackoff
+	var err error
+	for i := 0; r.maxRetries <= 0 || i < r.maxRetries; i++ {
+		timer := r.clock.NewTimer(delay)
+		select {
+		case <-r.closed:
+			timer.Stop()
+			return errors.Wrap(ErrRelayClosed, "reconnect failed")
+		case <-r.ctx.Done():
+			timer.Stop()
+			return errors.Wrap(r.ctx.Err(), "reconnect failed")
+		case <-timer.C():
+		}
+		var conn net.Conn
+		if conn, err = r.dial(); err == nil {
+			r.connMu.Lock()
+			r.Conn = conn
+			r.connMu.Unlock()
+			if isClosed(r.closed) {
+				conn.Close()
+				return errors.Wrap(ErrRelayClosed, "reconnect failed")
+			}
+			r.resetHeartbeat()
+			count(&r.counters.reconnects)
+			r.log().Printf("设备 %d 重连成功", r.SubDeviceID)
+			r.emit(EventReconnect, nil)
+			return nil
+		}
+		if delay *= 2; delay > maxReconnectBackoff {
+			delay = maxReconnectBackoff
+		}
+	}
+	return errors.Wrap(err, "reconnect failed")
+}
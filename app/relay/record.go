@@ -0,0 +1,167 @@
+package relay
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 记录格式：每帧依次为 8 字节接收时间（Unix 纳秒，大端）、2 字节帧长（大端）、帧数据
+const recordHeaderSize = 10
+
+// RecordTo 记录配置，将读取到的每个有效帧（校验通过、解码之前）连同接收时间写入 w，
+// 可用 ReplayFrom 重放。写入在读取协程中进行，失败时发送到 Errors 通道，不影响读取
+func RecordTo(w io.Writer) Option {
+	return func(r *Relay) {
+		r.recorder = &recorder{w: w}
+	}
+}
+
+// 帧记录器
+type recorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// 写入一条记录
+func (rec *recorder) record(at time.Time, frame []byte) error {
+	b := make([]byte, recordHeaderSize, recordHeaderSize+len(frame))
+	binary.BigEndian.PutUint64(b, uint64(at.UnixNano()))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(frame)))
+	b = append(b, frame...)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	_, err := rec.w.Write(b)
+	return err
+}
+
+// 读取一条记录
+func readRecord(r io.Reader) (time.Time, []byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return time.Time{}, nil, err
+	}
+	at := time.Unix(0, int64(binary.BigEndian.Uint64(header[:8])))
+	frame := make([]byte, binary.BigEndian.Uint16(header[8:]))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return time.Time{}, nil, errors.Wrap(err, "read record failed")
+	}
+	return at, frame, nil
+}
+
+// ReplayFrom 将 RecordTo 记录的帧作为连接重放，可直接传给 New。
+// 第一帧立即返回，之后按记录的接收时间间隔返回；写入的数据被丢弃。
+// 记录读完后 Read 阻塞到超时或 Close，与不再发送数据的设备一致
+func ReplayFrom(r io.Reader) net.Conn {
+	return &replayConn{src: r, closed: make(chan struct{})}
+}
+
+// 重放连接
+type replayConn struct {
+	src     io.Reader
+	mu      sync.Mutex // 保护读取状态，同一时间只有一个 Read
+	pending []byte     // 当前帧未读取的部分
+	due     time.Time  // 当前帧的返回时间
+	first   time.Time  // 第一帧的记录时间
+	start   time.Time  // 开始重放的时间
+	ended   bool
+
+	dmu          sync.Mutex
+	readDeadline time.Time
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 && !c.ended {
+		at, frame, err := readRecord(c.src)
+		if err == io.EOF {
+			c.ended = true
+		} else if err != nil {
+			return 0, err
+		} else {
+			if c.start.IsZero() {
+				c.first, c.start = at, time.Now()
+			}
+			c.pending, c.due = frame, c.start.Add(at.Sub(c.first))
+		}
+	}
+	var due <-chan time.Time
+	if len(c.pending) > 0 {
+		timer := time.NewTimer(time.Until(c.due))
+		defer timer.Stop()
+		due = timer.C
+	}
+	var timeout <-chan time.Time
+	c.dmu.Lock()
+	deadline := c.readDeadline
+	c.dmu.Unlock()
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-due:
+	case <-timeout:
+		return 0, replayTimeout{}
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *replayConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	return len(b), nil
+}
+
+func (c *replayConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *replayConn) LocalAddr() net.Addr  { return replayAddr{} }
+func (c *replayConn) RemoteAddr() net.Addr { return replayAddr{} }
+
+func (c *replayConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *replayConn) SetReadDeadline(t time.Time) error {
+	c.dmu.Lock()
+	c.readDeadline = t
+	c.dmu.Unlock()
+	return nil
+}
+
+func (c *replayConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// 重放读取超时
+type replayTimeout struct{}
+
+func (replayTimeout) Error() string   { return "i/o timeout" }
+func (replayTimeout) Timeout() bool   { return true }
+func (replayTimeout) Temporary() bool { return true }
+
+type replayAddr struct{}
+
+func (replayAddr) Network() string { return "replay" }
+func (replayAddr) String() string  { return "replay" }
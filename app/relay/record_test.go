@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var capture bytes.Buffer
+	client, server := net.Pipe()
+	r := New(nil, server, 0x1001, RecordTo(&capture))
+	if err := r.ReadLoop(DefaultFrameLength); err != nil {
+		t.Fatal(err)
+	}
+	writeFrames(t, client, testFrames[1:2], 1)
+	time.Sleep(30 * time.Millisecond)
+	writeFrames(t, client, testFrames[2:], 1)
+	deadline := time.Now().Add(time.Second)
+	for r.Stats().FramesRead < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("frames not read")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r.Offline()
+	client.Close()
+	r.Wait()
+
+	conn := ReplayFrom(bytes.NewReader(capture.Bytes()))
+	defer conn.Close()
+	b := make([]byte, DefaultFrameLength)
+	start := time.Now()
+	for i, want := range testFrames[1:] {
+		if _, err := io.ReadFull(conn, b); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, testStream(t, want)) {
+			t.Fatalf("replayed frame %d = % X", i, b)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Fatalf("replay took %v, want recorded interval", elapsed)
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := conn.Read(b); !isTimeout(err) {
+		t.Fatalf("read after end = %v, want timeout", err)
+	}
+
+	replayed := New(nil, ReplayFrom(bytes.NewReader(capture.Bytes())), 0x1001)
+	if err := replayed.ReadLoop(DefaultFrameLength); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(replayed)
+	deadline = time.Now().Add(time.Second)
+	for replayed.Stats().FramesRead < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("replayed frames not read")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if replayed.TH() != r.TH() || !replayed.InputState().Equal(r.InputState()) {
+		t.Fatalf("replayed state %+v, recorded %+v", replayed.Snapshot(), r.Snapshot())
+	}
+}
@@ -0,0 +1,53 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// SetRegister 写单个保持寄存器（功能码 06），用于模拟量输出等。
+// 寄存器地址与取值含义由设备决定，如 0~10000 对应 0~10V，需查阅设备手册；
+// 需要配置 Framing(FramingRTU) 或 Framing(FramingTCP)，等待设备回显，超时 1 秒。
+func (r *Relay) SetRegister(reg uint16, value uint16) error {
+	ctx, cancel := context.WithTimeout(context.Background(), modbusResponseTimeout)
+	defer cancel()
+	return r.SetRegisterContext(ctx, reg, value)
+}
+
+// SetRegisterContext 写单个保持寄存器，ctx 控制写入与等待回显的时间
+func (r *Relay) SetRegisterContext(ctx context.Context, reg uint16, value uint16) error {
+	return r.SetRegisterAt(ctx, r.SubDeviceID, reg, value)
+}
+
+// SetRegisterAt 通过本继电器的连接写地址为 addr 的从站的保持寄存器，用于多从站总线
+func (r *Relay) SetRegisterAt(ctx context.Context, addr uint16, reg uint16, value uint16) error {
+	if _, err := r.call(ctx, WriteRegisterFrame(addr, reg, r.encodeRegister(value))); err != nil {
+		return errors.Wrap(err, "set register failed")
+	}
+	return nil
+}
+
+// ReadRegister 读单个保持寄存器（功能码 03），需要配置 Framing(FramingRTU) 或 Framing(FramingTCP)，超时 1 秒
+func (r *Relay) ReadRegister(reg uint16) (uint16, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), modbusResponseTimeout)
+	defer cancel()
+	return r.ReadRegisterContext(ctx, reg)
+}
+
+// ReadRegisterContext 读单个保持寄存器，ctx 控制写入与等待响应的时间
+func (r *Relay) ReadRegisterContext(ctx context.Context, reg uint16) (uint16, error) {
+	return r.ReadRegisterAt(ctx, r.SubDeviceID, reg)
+}
+
+// ReadRegisterAt 通过本继电器的连接读地址为 addr 的从站的保持寄存器，用于多从站总线
+func (r *Relay) ReadRegisterAt(ctx context.Context, addr uint16, reg uint16) (uint16, error) {
+	resp, err := r.call(ctx, ReadHoldingRegistersFrame(addr, reg, 1))
+	if err != nil {
+		return 0, errors.Wrap(err, "read register failed")
+	}
+	if resp[2] != 2 {
+		return 0, errors.Errorf("read register failed: unexpected byte count %d", resp[2])
+	}
+	return r.decodeRegisters(resp[3:5])[0], nil
+}
@@ -1,9 +1,11 @@
 package relay
 
 import (
-	"fmt"
+	"context"
+	"encoding/binary"
 	"iot-sdk-go/sdk/device"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -11,32 +13,132 @@ import (
 
 // Relay 继电器设备
 type Relay struct {
+	lastSeen int64    // 最近一次收到有效帧的时间，原子操作需 64 位对齐，放在首位
+	counters counters // 运行统计，同样需 64 位对齐
+	aliveAt  int64    // 心跳计时起点，上线或重连时重置
+
 	Instance *device.Device
 
 	SubDeviceID uint16
 	Conn        net.Conn
+	connMu      sync.RWMutex // 保护重连时替换 Conn
 
-	OnlineTime string
+	OnlineTime time.Time // 创建时间，显示格式由调用方决定
 
 	middlewares []Middleware
+	persister   *persister
+	getters     GetPropertyFnMap
+	sink        func(PropertyType, Property)
+	encoder     func(PropertyType, Property) (map[string]interface{}, error)
+	postQueue   postQueue
+	mu          sync.RWMutex // 保护 outputState、inputState、th、analog、counts
 	outputState OutputStates
 	inputState  InputStates
 	th          TemperatureAndHumidity
+	analog      []AnalogInput
+	counts      Counters
+	processed   map[PropertyType]processedData
+	rawTH       TemperatureAndHumidity     // 平滑前的温湿度
+	seen        map[PropertyType]bool      // 是否已读取过该属性
+	raw         map[PropertyType][]byte    // 最近一次收到的原始帧
+	updated     map[PropertyType]time.Time // 最近一次收到的时间
+	requested   map[PropertyType]time.Time // 最近一次发送查询的时间
+	cfgMu       sync.RWMutex               // 保护可由 Apply 热更新的配置
 	keepAlive   time.Duration
+	retick      chan struct{} // SetKeepAlive 时关闭并替换，唤醒等待中的定时循环
+	frameLength int
+	framing     FramingMode
+	byteOrder   binary.ByteOrder
+	wordOrder   WordOrderMode
+	faults      *faultRegisters
+	inputModes  *inputModeRegisters
+	counterRegs *counterRegisters
+	passive     bool
+	scheduler   *Scheduler
+	adaptive    *adaptivePoller
+	breaker     *circuitBreaker
+	jitter      float64
+
+	outputLabels map[uint8]string
+	inputLabels  map[uint8]string
+
+	thInterval    time.Duration
+	inputInterval time.Duration
+	analogStart   uint16
+	analogCount   uint16
+	channelRegs   *channelRegisters
+
+	heartbeatTimeout time.Duration
+	writeMiddlewares []WriteMiddleware
+	writeTimeout     time.Duration
+	writeLock        chan struct{} // 串行化写入，总线上的继电器共用总线的锁
+	maxWriteFailures int
+	verifyTimeout    time.Duration
+	offOnDisconnect  bool
+	writeFailures    int32 // 连续写入失败次数，原子操作
+	readTimeout      time.Duration
+
+	tempOffset     float64
+	humidityOffset float64
+	clampHumidity  bool
+	smoothAlpha    float64
 
 	OfflineCallbackFn func(relay *Relay)
-	closed            chan bool
-}
+	onOffline         func(r *Relay, reason OfflineReason, err error)
+	offlineTimeout    time.Duration // 离线回调超时，0 为默认值
+	closed            chan struct{} // 下线时关闭，只由 offline 关闭一次
+	offlining         int32         // 已开始下线，原子操作
+	ctx               context.Context
+	verifyFrame       func(frame []byte) error
+	lengthPrefix      *lengthPrefix
+	decoder           Decoder
+	recorder          *recorder
+	sequence          *sequence
+
+	dial             func() (net.Conn, error)
+	reconnectBackoff time.Duration
+	maxRetries       int
+	initAttempts     int
+	initBackoff      time.Duration
+
+	onPropertyChange func(r *Relay, t PropertyType, old, new Property)
+	onInputEdge      func(r *Relay, route uint8, rising bool)
+	debounce         debouncer
+	callbacks        chan func()
+	logger           Logger
+	clock            Clock
+
+	errs       chan error
+	events     chan Event
+	data       chan Data
+	dataOn     int32 // 已调用 Data，原子操作
+	chanMu     sync.Mutex
+	chanClosed bool
+	lastErr    error     // 最近一次的错误，由 chanMu 保护
+	lastErrAt  time.Time // 最近一次错误的时间
 
-type Middleware func(*Relay, Data) Data
+	waiters      waiters      // 等待响应的查询
+	transactions transactions // 等待响应的 Modbus 请求
+	inFlight     inFlight     // 未收到响应的查询
+
+	pulseMu sync.Mutex
+	pulsing map[uint8]bool
+
+	loops        sync.WaitGroup // 后台协程
+	loopsMu      sync.Mutex     // 启动协程与关闭 closed 互斥，避免下线后 Add 与 Wait 并发
+	loopsStarted bool           // 已启动过后台协程，由 loopsMu 保护
+
+	onBus   bool // 挂在总线上，由总线读取数据
+	ownConn bool // 下线时是否关闭连接
+}
 
 // OutputStates 输出状态集合
 type OutputStates []OutputState
 
 // OutputState 输出状态
 type OutputState struct {
-	Route uint8
-	Value uint8
+	Route uint8 `json:"route"`
+	Value uint8 `json:"value"`
 }
 
 // InputStates 输入状态集合
@@ -44,14 +146,14 @@ type InputStates []InputState
 
 // InputState 输入状态
 type InputState struct {
-	Route uint8
-	Value uint8
+	Route uint8 `json:"route"`
+	Value uint8 `json:"value"`
 }
 
 // TemperatureAndHumidity 温湿度
 type TemperatureAndHumidity struct {
-	Temperature float64 // 温度
-	Humidity    float64 //湿度
+	Temperature float64 `json:"temperature"` // 温度
+	Humidity    float64 `json:"humidity"`    //湿度
 }
 
 // GetPropertyFnMap 根据属性类型获取属性的方法集合
@@ -66,7 +168,8 @@ type Property interface{}
 // Option 继电器配置
 type Option func(*Relay)
 
-// OfflineCallback 离线回调配置
+// OfflineCallback 离线回调配置，需要下线原因时使用 OfflineReasonCallback。
+// 回调在连接关闭之后执行，超时见 OfflineCallbackTimeout
 func OfflineCallback(cb func(relay *Relay)) Option {
 	return func(r *Relay) {
 		r.OfflineCallbackFn = cb
@@ -80,8 +183,47 @@ func Middlewares(middlewares ...Middleware) Option {
 	}
 }
 
-// New 创建继电器实例
-func New(DeviceInstance *device.Device, conn net.Conn, subDeviceID uint16, keepAlive time.Duration, options ...Option) *Relay {
+// DefaultFrameLength 默认帧长度
+const DefaultFrameLength = 13
+
+// FrameLength 帧长度配置，默认 13 字节。
+// 每次固定读取 n 字节作为一帧，设备发送变长帧时会导致帧错位；变长帧需配置 LengthPrefix，此时 n 为最大帧长。
+func FrameLength(n int) Option {
+	return func(r *Relay) {
+		r.frameLength = n
+	}
+}
+
+// DefaultKeepAlive 默认上报和询问间隔
+const DefaultKeepAlive = 5 * time.Second
+
+// KeepAlive 上报属性间隔配置，未配置 InquiryIntervals 时同时作为询问间隔，默认 5 秒，d <= 0 时使用默认值
+func KeepAlive(d time.Duration) Option {
+	return func(r *Relay) {
+		r.keepAlive = keepAliveOrDefault(d)
+	}
+}
+
+// d <= 0 时返回 DefaultKeepAlive，避免定时循环空转
+func keepAliveOrDefault(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultKeepAlive
+	}
+	return d
+}
+
+// NotOwnConn 不持有连接配置，下线时不关闭连接，由调用方管理，适用于多个使用方共享的连接。
+// 默认继电器持有连接，下线时关闭；总线上的继电器总是不持有共享连接，
+// Pool.Relay 返回的继电器下线时关闭连接即归还连接池，均不需要此配置。
+// 下线时通过读超时中断读取循环，退出后恢复；不应与 Reconnect 同时使用
+func NotOwnConn() Option {
+	return func(r *Relay) {
+		r.ownConn = false
+	}
+}
+
+// New 创建继电器实例，默认持有 conn，下线时关闭，见 NotOwnConn
+func New(DeviceInstance *device.Device, conn net.Conn, subDeviceID uint16, options ...Option) *Relay {
 	relay := &Relay{
 		Instance:    DeviceInstance,
 		Conn:        conn,
@@ -89,68 +231,236 @@ func New(DeviceInstance *device.Device, conn net.Conn, subDeviceID uint16, keepA
 		outputState: OutputStates{},
 		inputState:  InputStates{},
 		th:          TemperatureAndHumidity{},
-		keepAlive:   keepAlive,
-		closed:      make(chan bool),
-		OnlineTime:  time.Now().Format("2006-01-02 15:04:05"),
+		seen:        make(map[PropertyType]bool),
+		raw:         make(map[PropertyType][]byte),
+		updated:     make(map[PropertyType]time.Time),
+		requested:   make(map[PropertyType]time.Time),
+		callbacks:   make(chan func(), callbackQueueSize),
+		keepAlive:   DefaultKeepAlive,
+		retick:      make(chan struct{}),
+		frameLength: DefaultFrameLength,
+		byteOrder:   binary.BigEndian,
+		closed:      make(chan struct{}),
+		ctx:         context.Background(),
+		verifyFrame: verifyEnvelope,
+		decoder:     DefaultDecoder,
+		logger:      stdLogger{},
+		errs:        make(chan error, errorQueueSize),
+		events:      make(chan Event, eventQueueSize),
+		data:        make(chan Data, dataQueueSize),
+		pulsing:     make(map[uint8]bool),
+		ownConn:     true,
+		postQueue:   postQueue{size: defaultPostQueueSize, policy: Block},
+		clock:       realClock{},
+		writeLock:   make(chan struct{}, 1),
+	}
+	relay.getters = GetPropertyFnMap{
+		OUTPUTSTATE: relay.GetOutputState,
+		INPUTSTATE:  relay.GetInputState,
+		TH:          relay.GetTH,
 	}
 	for _, option := range options {
 		option(relay)
 	}
+	relay.OnlineTime = relay.now()
 	return relay
 }
 
+// NewWithKeepAlive 按旧签名创建继电器实例，等同于 New 加上 KeepAlive(keepAlive)。
+//
+// Deprecated: 使用 New 和 KeepAlive。
+func NewWithKeepAlive(DeviceInstance *device.Device, conn net.Conn, subDeviceID uint16, keepAlive time.Duration, options ...Option) *Relay {
+	return New(DeviceInstance, conn, subDeviceID, append([]Option{KeepAlive(keepAlive)}, options...)...)
+}
+
 // Init 初始化资源
 func (r *Relay) Init() error {
-	// 流读取循环
-	if err := r.ReadLoop(13); err != nil {
-		return errors.Wrap(err, "init relay failed")
-	}
-	// 主动询问状态循环
-	wfs := []WriteFn{
-		{
-			fn: r.InquiryTH,
-			d:  r.keepAlive,
-		},
-		{
-			fn: r.InquiryInputState,
-			d:  r.keepAlive,
-		},
-	}
-
-	if err := r.WriteLoop(wfs); err != nil {
-		return err
+	return r.InitContext(context.Background())
+}
+
+// InitContext 初始化资源，ctx 取消时与 Offline 一样下线并释放资源
+func (r *Relay) InitContext(ctx context.Context) error {
+	if isClosed(r.closed) {
+		return errors.Wrap(ErrRelayClosed, "init relay failed")
+	}
+	r.ctx = ctx
+	// 没有连接时按 InitRetry 建立初始连接
+	if !r.onBus && r.conn() == nil && r.dial != nil {
+		if err := r.dialInit(); err != nil {
+			return errors.Wrap(err, "init relay failed")
+		}
+	}
+	if !r.onBus && r.conn() == nil && r.dial == nil && r.initAttempts > 0 {
+		return errors.Wrap(ErrNotConnected, "init relay failed: InitRetry requires Reconnect to dial")
+	}
+	// 流读取循环，挂在总线上时由总线读取
+	if !r.onBus {
+		if err := r.ReadLoop(r.frameLength); err != nil {
+			return errors.Wrap(err, "init relay failed")
+		}
+	}
+	// 主动询问状态循环，询问命令为默认协议，Modbus 设备与被动模式不询问
+	if r.framing == FramingVendor && !r.passive {
+		wfs := []WriteFn{
+			{
+				fn: r.InquiryTH,
+				t:  TH,
+				interval: func() time.Duration {
+					return r.inquiryInterval(TH)
+				},
+			},
+			{
+				fn: r.InquiryInputState,
+				t:  INPUTSTATE,
+				interval: func() time.Duration {
+					return r.inquiryInterval(INPUTSTATE)
+				},
+			},
+		}
+
+		if err := r.WriteLoop(wfs); err != nil {
+			return err
+		}
+	}
+	// 模拟量输入读取循环
+	if r.framing != FramingVendor && !r.passive && r.analogCount > 0 {
+		if err := r.WriteLoop([]WriteFn{{fn: r.InquiryAnalogInputs, interval: r.keepAliveInterval}}); err != nil {
+			return err
+		}
+	}
+	// 计数读取循环
+	if r.framing != FramingVendor && !r.passive && r.counterRegs != nil {
+		if err := r.WriteLoop([]WriteFn{{fn: r.InquiryCounters, interval: r.keepAliveInterval}}); err != nil {
+			return err
+		}
+	}
+	r.goLoop(r.watchContext)
+	r.goLoop(r.callbackLoop)
+	if r.heartbeatTimeout > 0 {
+		r.resetHeartbeat()
+		r.goLoop(r.heartbeatLoop)
 	}
 	return nil
 }
 
-// Use 使用中间件
+// 启动后台协程，Wait 会等待其退出；已下线时不再启动
+func (r *Relay) goLoop(fn func()) {
+	r.loopsMu.Lock()
+	defer r.loopsMu.Unlock()
+	if isClosed(r.closed) {
+		return
+	}
+	r.loopsStarted = true
+	r.loops.Add(1)
+	go func() {
+		defer r.loops.Done()
+		fn()
+	}()
+}
+
+// Wait 阻塞直到读写循环等后台协程全部退出，可多次调用；
+// 在 Offline 之前调用会一直等到下线且协程退出为止。
+// 尚未启动后台协程（未调用 Online、ReadLoop 等）时立即返回，不等待之后启动的协程。
+func (r *Relay) Wait() {
+	r.loopsMu.Lock()
+	started := r.loopsStarted
+	r.loopsMu.Unlock()
+	if started {
+		r.loops.Wait()
+	}
+}
+
+// 监听 ctx，取消时下线
+func (r *Relay) watchContext() {
+	select {
+	case <-r.closed:
+	case <-r.ctx.Done():
+		r.offlineWith(OfflineContext, r.ctx.Err())
+	}
+}
+
+// Use 使用中间件，追加到已有中间件之后，可在运行中调用
 func (r *Relay) Use(fns ...Middleware) {
-	r.middlewares = append(r.middlewares, fns...)
+	r.cfgMu.Lock()
+	defer r.cfgMu.Unlock()
+	r.middlewares = append(r.middlewares[:len(r.middlewares):len(r.middlewares)], fns...)
+}
+
+// Middlewares 当前的中间件，按执行顺序，返回副本
+func (r *Relay) Middlewares() []Middleware {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return append([]Middleware{}, r.middlewares...)
+}
+
+// ClearMiddlewares 移除所有中间件，之后的数据不再经过中间件处理；
+// 正在执行的中间件链不受影响。重新配置时可先清空再 Use
+func (r *Relay) ClearMiddlewares() {
+	r.cfgMu.Lock()
+	defer r.cfgMu.Unlock()
+	r.middlewares = nil
 }
 
 // Online 上线
 func (r *Relay) Online(stateTypes []PropertyType) error {
-	fmt.Printf("%v 设备 %d 上线\n", time.Now().Format("2006-01-02 15:04:05"), r.SubDeviceID)
-	if err := r.Init(); err != nil {
+	return r.OnlineContext(context.Background(), stateTypes)
+}
+
+// OnlineContext 上线，继电器生命周期与 ctx 绑定；stateTypes 包含未知属性类型时不上线并返回错误
+func (r *Relay) OnlineContext(ctx context.Context, stateTypes []PropertyType) error {
+	if isClosed(r.closed) {
+		return errors.Wrap(ErrRelayClosed, "online failed")
+	}
+	if _, err := r.uniqueStateTypes(stateTypes); err != nil {
+		return errors.Wrap(err, "online failed")
+	}
+	r.log().Printf("设备 %d 上线", r.SubDeviceID)
+	if err := r.InitContext(ctx); err != nil {
 		return err
 	}
-	r.AutoPostProperty(stateTypes)
-	return nil
+	r.emit(EventOnline, nil)
+	return r.AutoPostProperty(stateTypes)
+}
+
+// Uptime 自创建以来的在线时长
+func (r *Relay) Uptime() time.Duration {
+	return r.now().Sub(r.OnlineTime)
 }
 
-// Offline 下线
+// Offline 下线，可在多个协程中同时调用、也可在离线回调中调用，只有第一次生效，离线回调只触发一次。
+// 其他调用不等待下线完成，需要等待时使用 Done 或 Wait
 func (r *Relay) Offline() {
-	fmt.Printf("%v 设备 %d 下线\n", time.Now().Format("2006-01-02 15:04:05"), r.SubDeviceID)
-	r.Conn.Close()
-	if !isClosed(r.closed) {
-		close(r.closed)
+	r.offlineWith(OfflineExplicit, nil)
+}
+
+// Done 下线时关闭的通道，与 context.Context 的 Done 用法相同
+func (r *Relay) Done() <-chan struct{} {
+	return r.closed
+}
+
+func (r *Relay) offline(reason OfflineReason, err error) {
+	r.log().Printf("设备 %d 下线: %s", r.SubDeviceID, reason)
+	r.offBeforeDisconnect()
+	if conn := r.conn(); conn != nil {
+		if r.ownConn {
+			conn.Close()
+		} else if !r.onBus {
+			// 不关闭连接，中断阻塞中的读取
+			conn.SetReadDeadline(time.Now())
+		}
 	}
-	if r.OfflineCallbackFn != nil {
-		r.OfflineCallbackFn(r)
+	r.loopsMu.Lock()
+	close(r.closed)
+	r.loopsMu.Unlock()
+	if r.scheduler != nil {
+		r.scheduler.remove(r)
 	}
+	r.emit(EventOffline, nil)
+	r.closeChannels()
+	r.runOfflineCallbacks(reason, err)
 }
 
-func isClosed(ch <-chan bool) bool {
+func isClosed(ch <-chan struct{}) bool {
 	select {
 	case <-ch:
 		return true
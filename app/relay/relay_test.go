@@ -0,0 +1,1258 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"relay/app/relay/relaytest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var testFrames = []string{
+	"A0 10 01 AA 00 55 00 00 00 00 00 00 A7",
+	"A0 10 01 1B 0F 00 00 00 00 00 00 00 A7",
+	"A0 10 01 2A 01 19 05 3C 02 00 00 00 A7",
+}
+
+func writeFrames(t *testing.T, conn net.Conn, frames []string, times int) {
+	for i := 0; i < times; i++ {
+		b, err := commandFormatter(frames[i%len(frames)])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := conn.Write(b); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+}
+
+func TestStateRace(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writeFrames(t, client, testFrames, 300)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			r.GetOutputState()
+			r.GetInputState()
+			r.GetTH()
+		}
+	}
+}
+
+func TestStateCopy(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second))
+	r.SaveOutputState([]byte{0xA0, 0x10, 0x01, 0xAA, 0x00, 0x01, 0, 0, 0, 0, 0, 0, 0xA7})
+	states := r.OutputState()
+	states[0].Value = 9
+	_ = append(states, OutputState{Route: 9, Value: 1})
+	if got := r.OutputState(); got[0].Value == 9 || len(got) != 8 {
+		t.Fatalf("internal state mutated: %v", got)
+	}
+	// 事件中的数据同样是副本
+	select {
+	case e := <-r.Events():
+		e.Payload.(Data).Data.(OutputStates)[0].Value = 7
+	default:
+		t.Fatal("no property update event")
+	}
+	if got := r.OutputState(); got[0].Value == 7 {
+		t.Fatalf("internal state mutated through event: %v", got)
+	}
+}
+
+func TestInitContextCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		b := make([]byte, 13)
+		for {
+			if _, err := client.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	offline := make(chan struct{}, 1)
+	r := New(nil, server, 0x1001, KeepAlive(time.Second), OfflineCallback(func(*Relay) {
+		offline <- struct{}{}
+	}))
+	if err := r.InitContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-offline:
+	case <-time.After(time.Second):
+		t.Fatal("relay not offline after context cancel")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	client, server := net.Pipe()
+	conns := make(chan net.Conn, 1)
+	dial := func() (net.Conn, error) {
+		c, s := net.Pipe()
+		conns <- c
+		return s, nil
+	}
+	r := New(nil, server, 0x1001, KeepAlive(time.Second), Reconnect(dial, time.Millisecond, 3))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	client.Close()
+
+	select {
+	case c := <-conns:
+		defer c.Close()
+		writeFrames(t, c, testFrames[:1], 1)
+	case <-time.After(time.Second):
+		t.Fatal("relay did not reconnect")
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(r.OutputState()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no state read after reconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOnPropertyChange(t *testing.T) {
+	changes := make(chan PropertyType, 10)
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second), OnPropertyChange(func(_ *Relay, pt PropertyType, old, new Property) {
+		changes <- pt
+	}))
+	go r.callbackLoop()
+	defer close(r.closed)
+
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	r.SaveTH(frame)
+	frame[5] = 0x1A
+	r.SaveTH(frame)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatalf("got %d changes, want 2", i)
+		}
+	}
+	select {
+	case <-changes:
+		t.Fatal("unchanged reading reported as change")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestErrors(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	writeFrames(t, client, []string{"A0 10 01 AA 00 55 00 00 00 00 00 00 00"}, 1)
+	select {
+	case err := <-r.Errors():
+		if err == nil {
+			t.Fatal("want frame error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("bad frame not reported")
+	}
+	r.Offline()
+	for range r.Errors() {
+	}
+}
+
+func TestPulseOutput(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	defer stop(r)
+	frames := make(chan []byte, 2)
+	go func() {
+		for {
+			b := make([]byte, 13)
+			if _, err := client.Read(b); err != nil {
+				return
+			}
+			frames <- b
+		}
+	}()
+
+	if err := r.PulseOutput(3, 1, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PulseOutput(3, 1, 20*time.Millisecond); err != ErrPulseInProgress {
+		t.Fatalf("concurrent pulse: got %v", err)
+	}
+	for _, state := range []StateCMDType{ON, OFF} {
+		select {
+		case b := <-frames:
+			if b[5] != 0x04 || b[6] != state[1]-'0' {
+				t.Fatalf("unexpected frame % X", b)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("pulse frame not written")
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		b := make([]byte, 13)
+		for {
+			if _, err := client.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+	r := New(nil, server, 0x1001, KeepAlive(time.Millisecond))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		r.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Wait returned before Offline")
+	case <-time.After(20 * time.Millisecond):
+	}
+	r.Offline()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("loops did not exit after Offline")
+	}
+	r.Wait()
+	// 下线后不再启动协程，Wait 不与 Add 并发
+	r.goLoop(func() { t.Error("loop started after Offline") })
+	r.Wait()
+}
+
+func TestWaitNotStarted(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second))
+	done := make(chan struct{})
+	go func() {
+		r.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Wait blocked before loops started")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	r := New(nil, &net.TCPConn{}, 0x1001, KeepAlive(time.Second))
+	frame, _ := commandFormatter(testFrames[1])
+	r.SaveInputState(frame)
+	r.Offline()
+	var types []EventType
+	for e := range r.Events() {
+		if e.SubDeviceID != 0x1001 {
+			t.Fatalf("event sub device id %d", e.SubDeviceID)
+		}
+		types = append(types, e.Type)
+	}
+	if len(types) != 2 || types[0] != EventPropertyUpdate || types[1] != EventOffline {
+		t.Fatalf("events %v", types)
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		b := make([]byte, 13)
+		for {
+			if _, err := client.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+	reasons := make(chan OfflineReason, 1)
+	r := New(nil, server, 0x1001, KeepAlive(time.Hour), HeartbeatTimeout(20*time.Millisecond),
+		OfflineReasonCallback(func(_ *Relay, reason OfflineReason, err error) {
+			if err == nil {
+				t.Error("heartbeat offline without error")
+			}
+			reasons <- reason
+		}))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-reasons:
+		if reason != OfflineHeartbeat {
+			t.Fatalf("offline reason %s, want %s", reason, OfflineHeartbeat)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("relay not offline after heartbeat timeout")
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second), WriteTimeout(10*time.Millisecond))
+	done := make(chan error)
+	go func() {
+		done <- r.SetOutput(1, 1)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("write to stalled conn succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write blocked past timeout")
+	}
+	if err := <-r.Errors(); err == nil {
+		t.Fatal("timeout not reported")
+	}
+}
+
+func TestWriteWithoutConn(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second))
+	if err := r.SetOutput(1, 1); errors.Cause(err) != ErrNotConnected {
+		t.Fatalf("set output without conn: %v", err)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	first := make(chan error, 1)
+	go func() {
+		first <- r.WriteFrame([]byte{0x01, 0x02})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// 等待中的写入取消不影响进行中的写入
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.WriteFrameContext(ctx, []byte{0x03}); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("queued write = %v, want deadline exceeded", err)
+	}
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(client, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-first; err != nil {
+		t.Fatalf("in-progress write aborted: %v", err)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second), ReadTimeout(5*time.Millisecond))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	time.Sleep(20 * time.Millisecond)
+	writeFrames(t, client, testFrames[:1], 1)
+	deadline := time.Now().Add(time.Second)
+	for len(r.OutputState()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("frame not read after timeouts")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStats(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	writeFrames(t, client, []string{"A0 10 01 AA 00 55 00 00 00 00 00 00 00"}, 1)
+	<-r.Errors()
+	writeFrames(t, client, testFrames, 3)
+	deadline := time.Now().Add(time.Second)
+	for r.Stats().FramesRead < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats %+v", r.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s := r.Stats(); s.CRCFailures != 1 || s.LastSeen.IsZero() {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestOfflineTwice(t *testing.T) {
+	_, server := net.Pipe()
+	calls := 0
+	r := New(nil, server, 0x1001, KeepAlive(time.Second), OfflineCallback(func(*Relay) {
+		calls++
+	}))
+	r.Offline()
+	r.Offline()
+	if calls != 1 {
+		t.Fatalf("offline callback called %d times", calls)
+	}
+}
+
+func TestInitAfterOffline(t *testing.T) {
+	_, server := net.Pipe()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	r.Offline()
+	if err := r.Init(); errors.Cause(err) != ErrRelayClosed {
+		t.Fatalf("Init after Offline: %v", err)
+	}
+	if err := r.Online(nil); errors.Cause(err) != ErrRelayClosed {
+		t.Fatalf("Online after Offline: %v", err)
+	}
+}
+
+func TestQueryTH(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		b := make([]byte, 13)
+		if _, err := io.ReadFull(client, b); err != nil || b[3] != 0x2A {
+			t.Errorf("inquiry % X, %v", b, err)
+			return
+		}
+		writeFrames(t, client, testFrames[1:], 2)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	th, err := r.QueryTH(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Temperature != 25.5 || th.Humidity != 60.2 {
+		t.Fatalf("th %+v", th)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.QueryOutputState(ctx); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("query without response: %v", err)
+	}
+}
+
+func TestNewTLSPlaintext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			writeFrames(t, conn, testFrames[:1], 1)
+			conn.Close()
+		}
+	}()
+	r, err := NewTLS(nil, ln.Addr().String(), nil, time.Second, 0x1001, KeepAlive(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(r.OutputState()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no state read over dialed conn")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second), MaxInFlight(1))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	writes := make(chan []byte, 4)
+	go func() {
+		for {
+			b := make([]byte, 13)
+			if _, err := io.ReadFull(client, b); err != nil {
+				return
+			}
+			writes <- b
+		}
+	}()
+	r.InquiryTH()
+	<-writes
+	r.InquiryTH()
+	r.InquiryInputState()
+	select {
+	case b := <-writes:
+		t.Fatalf("inquiry sent while one in flight: % X", b)
+	case <-time.After(20 * time.Millisecond):
+	}
+	writeFrames(t, client, testFrames[2:], 1)
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.InquiryInputState()
+		select {
+		case <-writes:
+			return
+		case <-time.After(5 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("inquiry still blocked after response")
+		}
+	}
+}
+
+func TestUptime(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second))
+	r.OnlineTime = time.Now().Add(-time.Hour)
+	if d := r.Uptime(); d < time.Hour || d > time.Hour+time.Second {
+		t.Fatalf("uptime %v", d)
+	}
+}
+
+func TestLastSeen(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second))
+	if !r.LastSeen().IsZero() {
+		t.Fatal("last seen set before any frame")
+	}
+	r.resetHeartbeat()
+	if !r.LastSeen().IsZero() {
+		t.Fatal("heartbeat reset counted as frame")
+	}
+	frame, _ := commandFormatter(testFrames[0])
+	r.handleFrame(frame)
+	if time.Since(r.LastSeen()) > time.Second {
+		t.Fatalf("last seen %v", r.LastSeen())
+	}
+}
+
+func TestMaxWriteFailures(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Millisecond), WriteTimeout(time.Millisecond), MaxWriteFailures(3))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	wfs := []WriteFn{{d: time.Millisecond, fn: r.InquiryTH}}
+	if err := r.WriteLoop(wfs); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r.closed:
+	case <-time.After(time.Second):
+		t.Fatal("relay not offline after repeated write failures")
+	}
+	r.Wait()
+}
+
+func TestPassiveMode(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Millisecond), PassiveMode())
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	client.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	b := make([]byte, 13)
+	if n, err := client.Read(b); err == nil {
+		t.Fatalf("passive relay wrote % X", b[:n])
+	}
+}
+
+func TestOnInputEdge(t *testing.T) {
+	type edge struct {
+		route  uint8
+		rising bool
+	}
+	edges := make(chan edge, 10)
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second), OnInputEdge(func(_ *Relay, route uint8, rising bool) {
+		edges <- edge{route, rising}
+	}))
+	go r.callbackLoop()
+	defer close(r.closed)
+
+	frame, _ := commandFormatter(testFrames[1])
+	r.SaveInputState(frame)
+	frame[4] = 0x0E
+	r.SaveInputState(frame)
+	frame[4] = 0x1E
+	r.SaveInputState(frame)
+	for _, want := range []edge{{1, false}, {5, true}} {
+		select {
+		case got := <-edges:
+			if got != want {
+				t.Fatalf("edge %+v, want %+v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("edge %+v not reported", want)
+		}
+	}
+	select {
+	case got := <-edges:
+		t.Fatalf("unexpected edge %+v", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	clock := relaytest.NewFakeClock(time.Now())
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second), Debounce(30*time.Millisecond), WithClock(clock))
+	defer close(r.closed)
+	frame, _ := commandFormatter(testFrames[1])
+	r.SaveInputState(frame)
+	// 抖动后变回原值
+	frame[4] = 0x0E
+	r.SaveInputState(frame)
+	frame[4] = 0x0F
+	r.SaveInputState(frame)
+	// 稳定变化
+	frame[4] = 0x1F
+	r.SaveInputState(frame)
+	input := func(route uint8) uint8 {
+		for _, state := range r.InputState() {
+			if state.Route == route {
+				return state.Value
+			}
+		}
+		return 0xFF
+	}
+	if input(5) != 0 || input(1) != 1 {
+		t.Fatalf("input change accepted before debounce window: %v", r.InputState())
+	}
+	// 第 1 路的等待已停止，只剩第 5 路的等待
+	if n := clock.Waiters(); n != 1 {
+		t.Fatalf("%d debounce timers, want 1", n)
+	}
+	clock.Advance(29 * time.Millisecond)
+	if input(5) != 0 {
+		t.Fatalf("input change accepted before debounce window: %v", r.InputState())
+	}
+	clock.Advance(time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for input(5) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("inputs after debounce %v", r.InputState())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if input(1) != 1 {
+		t.Fatalf("inputs after debounce %v", r.InputState())
+	}
+}
+
+func TestApply(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Hour), InquiryIntervals(time.Hour, time.Hour))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	b := make([]byte, 13)
+	for i := 0; i < 2; i++ {
+		if _, err := io.ReadFull(client, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Apply(Calibration(1, 0), InquiryIntervals(time.Hour, time.Millisecond))
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	if th := r.TH(); th.Temperature != 26.5 {
+		t.Fatalf("calibration not applied: %+v", th)
+	}
+	if got := r.inquiryInterval(INPUTSTATE); got != time.Millisecond {
+		t.Fatalf("input interval %v", got)
+	}
+}
+
+func TestVerifyWrites(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second), VerifyWrites(50*time.Millisecond))
+	if err := r.ReadLoop(13); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	go func() {
+		b := make([]byte, 13)
+		// 第一次回复 3 路闭合，第二次不回复
+		if _, err := io.ReadFull(client, b); err != nil {
+			return
+		}
+		writeFrames(t, client, []string{"A0 10 01 AA 00 04 00 00 00 00 00 00 A7"}, 1)
+		io.ReadFull(client, b)
+	}()
+	if err := r.SetOutput(3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetOutput(2, 1); errors.Cause(err) != ErrNotConfirmed {
+		t.Fatalf("unconfirmed write: %v", err)
+	}
+}
+
+func TestSetOutputContextCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.SetOutputContext(ctx, 1, 1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if errors.Cause(err) != context.Canceled {
+			t.Fatalf("cancelled write: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write not aborted by cancel")
+	}
+	if len(r.OutputState()) != 0 {
+		t.Fatal("output state updated after cancelled write")
+	}
+}
+
+func TestPropertySink(t *testing.T) {
+	sim := NewSimConn(0x1001)
+	sim.SetTH(TemperatureAndHumidity{Temperature: 20, Humidity: 50})
+	posted := make(chan PropertyType, 16)
+	r := New(nil, sim, 0x1001, KeepAlive(5*time.Millisecond), PropertySink(func(pt PropertyType, p Property) {
+		if p == nil {
+			pt = "NIL"
+		}
+		select {
+		case posted <- pt:
+		default:
+		}
+	}))
+	if err := r.Online([]PropertyType{TH}); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	select {
+	case pt := <-posted:
+		if pt != TH {
+			t.Fatalf("posted %s", pt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing posted to sink")
+	}
+}
+
+func TestPropertyEncoder(t *testing.T) {
+	r := New(nil, nil, 0x1001, PropertyEncoder(func(pt PropertyType, p Property) (map[string]interface{}, error) {
+		th, ok := p.(TemperatureAndHumidity)
+		if !ok {
+			return nil, errors.New("unsupported")
+		}
+		return map[string]interface{}{
+			"7": th.Temperature,
+			"3": []interface{}{"th", th.Humidity},
+		}, nil
+	}))
+	properties, err := r.encodeProperty(TH, TemperatureAndHumidity{Temperature: 20, Humidity: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(properties) != 2 || properties[0].PropertyID != 3 || properties[1].PropertyID != 7 {
+		t.Fatalf("properties %+v", properties)
+	}
+	if properties[0].SubDeviceID != 0x1001 || len(properties[0].Value) != 2 || properties[0].Value[1] != 50.0 {
+		t.Fatalf("humidity %+v", properties[0])
+	}
+	if len(properties[1].Value) != 1 || properties[1].Value[0] != 20.0 {
+		t.Fatalf("temperature %+v", properties[1])
+	}
+	if _, err := r.encodeProperty(OUTPUTSTATE, OutputStates{}); err == nil {
+		t.Fatal("expected encoder error")
+	}
+	r.encoder = func(PropertyType, Property) (map[string]interface{}, error) {
+		return map[string]interface{}{"temperature": 1}, nil
+	}
+	if _, err := r.encodeProperty(TH, TemperatureAndHumidity{}); err == nil {
+		t.Fatal("expected invalid property id error")
+	}
+}
+
+func TestAllOff(t *testing.T) {
+	sim := NewSimConn(0x1001)
+	r := New(nil, sim, 0x1001, KeepAlive(time.Hour), OffOnDisconnect())
+	if err := r.SetOutputs(OutputStates{{Route: 1, Value: 1}, {Route: 7, Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	waitOutputs := func(want uint8) {
+		deadline := time.Now().Add(time.Second)
+		for sim.Outputs() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("sim outputs %02X, want %02X", sim.Outputs(), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitOutputs(0x41)
+	if err := r.AllOff(); err != nil {
+		t.Fatal(err)
+	}
+	waitOutputs(0)
+	for _, state := range r.OutputState() {
+		if state.Value != 0 {
+			t.Fatalf("output state %v", r.OutputState())
+		}
+	}
+
+	if err := r.SetOutput(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	waitOutputs(0x02)
+	r.Offline()
+	waitOutputs(0)
+}
+
+func TestKeepAliveNonPositive(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(0))
+	if d := r.keepAliveInterval(); d != DefaultKeepAlive {
+		t.Fatalf("KeepAlive(0) interval %v, want %v", d, DefaultKeepAlive)
+	}
+	r.SetKeepAlive(time.Second)
+	r.SetKeepAlive(-time.Second)
+	if d := r.keepAliveInterval(); d != DefaultKeepAlive {
+		t.Fatalf("SetKeepAlive(-1s) interval %v, want %v", d, DefaultKeepAlive)
+	}
+	if d := r.inquiryInterval(TH); d != DefaultKeepAlive {
+		t.Fatalf("inquiry interval %v, want %v", d, DefaultKeepAlive)
+	}
+}
+
+func TestSetKeepAlive(t *testing.T) {
+	conn := relaytest.NewFakeConn()
+	r := New(nil, conn, 0x1001, KeepAlive(time.Hour))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	time.Sleep(20 * time.Millisecond)
+	if n := len(conn.Writes()); n != 2 {
+		t.Fatalf("writes before SetKeepAlive = %d, want 2", n)
+	}
+	r.SetKeepAlive(10 * time.Millisecond)
+	time.Sleep(55 * time.Millisecond)
+	if n := len(conn.Writes()); n < 8 {
+		t.Fatalf("writes after SetKeepAlive = %d, want >= 8", n)
+	}
+	if r.keepAliveInterval() != 10*time.Millisecond {
+		t.Fatalf("keepAlive = %v", r.keepAliveInterval())
+	}
+}
+
+func TestInitRetry(t *testing.T) {
+	attempts := 0
+	dial := func() (net.Conn, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return relaytest.NewFakeConn(), nil
+	}
+	r := New(nil, nil, 0x1001, Reconnect(dial, time.Millisecond, 0), InitRetry(3, time.Millisecond), WithLogger(nopLogger{}))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r.Offline()
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+
+	failures := 0
+	refuse := func() (net.Conn, error) {
+		failures++
+		return nil, errors.New("connection refused")
+	}
+	r = New(nil, nil, 0x1001, Reconnect(refuse, time.Millisecond, 0), InitRetry(2, time.Millisecond), WithLogger(nopLogger{}))
+	if err := r.Init(); err == nil || errors.Cause(err).Error() != "connection refused" {
+		t.Fatalf("Init after failed attempts: %v", err)
+	}
+	if failures != 2 {
+		t.Fatalf("attempts = %d, want 2", failures)
+	}
+
+	// 没有配置 Reconnect 时无法拨号，返回明确的错误
+	r = New(nil, nil, 0x1001, InitRetry(3, time.Millisecond), WithLogger(nopLogger{}))
+	if err := r.Init(); errors.Cause(err) != ErrNotConnected || !strings.Contains(err.Error(), "Reconnect") {
+		t.Fatalf("InitRetry without Reconnect: %v", err)
+	}
+}
+
+func TestOnlineImmediatePost(t *testing.T) {
+	sim := NewSimConn(0x1001)
+	sim.SetTH(TemperatureAndHumidity{Temperature: 21.5, Humidity: 40})
+	posted := make(chan Property, 4)
+	r := New(nil, sim, 0x1001, KeepAlive(time.Hour), PropertySink(func(pt PropertyType, p Property) {
+		posted <- p
+	}))
+	if err := r.Online([]PropertyType{TH, OUTPUTSTATE}); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	select {
+	case p := <-posted:
+		if th, ok := p.(TemperatureAndHumidity); !ok || th.Temperature != 21.5 {
+			t.Fatalf("posted %v", p)
+		}
+	case <-time.After(initialPostTimeout + 500*time.Millisecond):
+		t.Fatal("nothing posted after online")
+	}
+	select {
+	case p := <-posted:
+		t.Fatalf("posted unreceived property %v", p)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestPostQueue(t *testing.T) {
+	for _, c := range []struct {
+		policy OverflowPolicy
+		want   PropertyType
+	}{{DropOldest, INPUTSTATE}, {DropNewest, OUTPUTSTATE}} {
+		r := New(nil, nil, 0x1001, PostQueue(1, c.policy))
+		queue := make(chan postItem, r.postQueue.size)
+		r.enqueuePost(queue, postItem{OUTPUTSTATE, OutputStates{}})
+		r.enqueuePost(queue, postItem{INPUTSTATE, InputStates{}})
+		if item := <-queue; item.t != c.want {
+			t.Fatalf("policy %d kept %s, want %s", c.policy, item.t, c.want)
+		}
+		if n := r.Stats().PostsDropped; n != 1 {
+			t.Fatalf("policy %d dropped %d, want 1", c.policy, n)
+		}
+	}
+
+	r := New(nil, nil, 0x1001, PostQueue(1, Block))
+	queue := make(chan postItem, 1)
+	r.enqueuePost(queue, postItem{TH, TemperatureAndHumidity{}})
+	done := make(chan struct{})
+	go func() {
+		r.enqueuePost(queue, postItem{TH, TemperatureAndHumidity{}})
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Block policy did not block on full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+	<-queue
+	<-done
+}
+
+func TestConcurrentOffline(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		var calls int32
+		conn := relaytest.NewFakeConn()
+		var r *Relay
+		r = New(nil, conn, 0x1001, KeepAlive(time.Millisecond), WithLogger(nopLogger{}), OfflineCallback(func(*Relay) {
+			atomic.AddInt32(&calls, 1)
+			r.Offline()
+		}))
+		if err := r.Init(); err != nil {
+			t.Fatal(err)
+		}
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for j := 0; j < 50; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				<-start
+				switch j % 4 {
+				case 0:
+					r.SetOutput(1, 1)
+				case 1:
+					r.Snapshot()
+				default:
+					r.Offline()
+				}
+			}(j)
+		}
+		close(start)
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			<-r.Done()
+			r.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("concurrent offline deadlocked")
+		}
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Fatalf("offline callback called %d times", n)
+		}
+	}
+}
+
+func TestOfflineCallbackTimeout(t *testing.T) {
+	conn := relaytest.NewFakeConn()
+	release := make(chan struct{})
+	defer close(release)
+	r := New(nil, conn, 0x1001, WithLogger(nopLogger{}), OfflineCallbackTimeout(20*time.Millisecond), OfflineCallback(func(*Relay) {
+		<-release
+	}))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		r.Offline()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("offline blocked by slow callback")
+	}
+	if _, err := conn.Write([]byte{0x00}); err == nil {
+		t.Fatal("conn not closed before callback")
+	}
+}
+
+func TestDataChannel(t *testing.T) {
+	conn := relaytest.NewFakeConn()
+	dropInputs := func(_ *Relay, data Data) (Data, bool) {
+		return data, data.PropertyType != INPUTSTATE
+	}
+	r := New(nil, conn, 0x1001, KeepAlive(time.Hour), PassiveMode(), WithLogger(nopLogger{}), Middlewares(dropInputs))
+	data := r.Data()
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.FeedHex(testFrames...); err != nil {
+		t.Fatal(err)
+	}
+	var got []PropertyType
+	for len(got) < 2 {
+		select {
+		case d := <-data:
+			got = append(got, d.PropertyType)
+			if d.Raw == nil || d.Time.IsZero() {
+				t.Fatalf("data %+v missing raw frame or time", d)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("received %v", got)
+		}
+	}
+	if got[0] != OUTPUTSTATE || got[1] != TH {
+		t.Fatalf("received %v, want outputs and th", got)
+	}
+	r.Offline()
+	if _, ok := <-data; ok {
+		t.Fatal("data channel not closed after offline")
+	}
+}
+
+func TestDataChannelDedupPost(t *testing.T) {
+	conn := relaytest.NewFakeConn()
+	posted := make(chan Property, 4)
+	r := New(nil, conn, 0x1001, KeepAlive(time.Hour), PassiveMode(), WithLogger(nopLogger{}), Middlewares(DedupMiddleware()),
+		PropertySink(func(_ PropertyType, p Property) {
+			posted <- p
+		}))
+	data := r.Data()
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+	if err := conn.FeedHex(testFrames[2]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-data:
+		if d.PropertyType != TH {
+			t.Fatalf("received %s", d.PropertyType)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing received from data channel")
+	}
+	// 数据流已执行过去重，同一帧的上报不应被当作重复丢弃
+	if err := r.AutoPostProperty([]PropertyType{TH}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-posted:
+		if _, ok := p.(TemperatureAndHumidity); !ok {
+			t.Fatalf("posted %v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deduplicated frame not posted")
+	}
+	// 同一帧再次获取时按去重丢弃
+	if p := r.GetTH(); p != nil {
+		t.Fatalf("duplicate get = %v, want nil", p)
+	}
+}
+
+func TestNotOwnConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	r := New(nil, server, 0x1001, KeepAlive(time.Hour), PassiveMode(), WithLogger(nopLogger{}), NotOwnConn())
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r.Offline()
+	done := make(chan struct{})
+	go func() {
+		r.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("read loop not stopped")
+	}
+	// 连接仍可由调用方使用
+	go client.Write([]byte{0x01})
+	b := make([]byte, 1)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := server.Read(b); err != nil || b[0] != 0x01 {
+		t.Fatalf("conn closed after offline: %v", err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	conn := relaytest.NewFakeConn()
+	r := New(nil, conn, 0x1001, KeepAlive(10*time.Millisecond), PassiveMode(), WithLogger(nopLogger{}))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Health(); h.Status != HealthOK {
+		t.Fatalf("health after init %+v", h)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if h := r.Health(); h.Status != HealthDegraded || len(h.Reasons) != 1 || h.Reasons[0] != "no data received" {
+		t.Fatalf("health without data %+v", h)
+	}
+	// 过期阈值为 3 倍 KeepAlive，错误帧之后仍在阈值内
+	if err := conn.FeedHex(testFrames[2], "A0 10 01 2A 01 19 05 3C 00 00 00 00 A8"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for r.Stats().CRCFailures == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("bad frame not reported")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if h := r.Health(); h.Status != HealthDegraded || !strings.HasPrefix(h.Reasons[len(h.Reasons)-1], "recent error") {
+		t.Fatalf("health after bad frame %+v", h)
+	}
+	r.Offline()
+	b, err := json.Marshal(r.Health())
+	if err != nil || !strings.Contains(string(b), `"status":"offline"`) {
+		t.Fatalf("health json %s, %v", b, err)
+	}
+}
+
+func TestResetState(t *testing.T) {
+	changes := make(chan Property, 4)
+	r := New(nil, nil, 0x1001, KeepAlive(time.Hour), OnPropertyChange(func(_ *Relay, pt PropertyType, old, new Property) {
+		if pt == INPUTSTATE {
+			changes <- old
+		}
+	}))
+	r.goLoop(r.callbackLoop)
+	defer close(r.closed)
+	frame, _ := commandFormatter(testFrames[1])
+	r.SaveInputState(frame)
+	<-changes
+	r.SaveInputState(frame)
+	r.ResetState()
+	if len(r.InputState()) != 0 || r.Snapshot().TH != (TemperatureAndHumidity{}) {
+		t.Fatalf("state after reset %+v", r.Snapshot())
+	}
+	r.SaveInputState(frame)
+	select {
+	case old := <-changes:
+		if states, _ := old.(InputStates); len(states) != 0 {
+			t.Fatalf("change after reset from %v, want zero", old)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first reading after reset not reported as change")
+	}
+}
+
+func TestAdaptivePolling(t *testing.T) {
+	clock := relaytest.NewFakeClock(time.Now())
+	r := New(nil, relaytest.NewFakeConn(), 0x1001, WithClock(clock), AdaptivePolling(10*time.Millisecond, time.Second))
+	frame, _ := commandFormatter(testFrames[2])
+	if d := r.Stats().PollInterval; d != 10*time.Millisecond {
+		t.Fatalf("initial interval %v", d)
+	}
+	// 响应时间 50ms，间隔放大到 10 倍
+	if err := r.InquiryTH(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(50 * time.Millisecond)
+	r.SaveTH(frame)
+	if d := r.Stats().PollInterval; d != 500*time.Millisecond {
+		t.Fatalf("interval after slow response %v", d)
+	}
+	// 上一次查询未响应，间隔加倍，不超过上限
+	r.InquiryTH()
+	clock.Advance(time.Millisecond)
+	r.InquiryTH()
+	if d := r.Stats().PollInterval; d != time.Second {
+		t.Fatalf("interval after missed response %v", d)
+	}
+	// 响应变快后逐步缩短
+	clock.Advance(time.Millisecond)
+	r.SaveTH(frame)
+	if d := r.Stats().PollInterval; d >= time.Second || d < 500*time.Millisecond {
+		t.Fatalf("interval after fast response %v", d)
+	}
+}
@@ -0,0 +1,237 @@
+// Package relaytest 提供测试读写循环用的内存连接与模拟时钟
+package relaytest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewPipe 创建一对同步内存连接，一端模拟设备，另一端交给继电器
+func NewPipe() (device net.Conn, relay net.Conn) {
+	return net.Pipe()
+}
+
+// FakeConn 模拟设备连接：Feed 写入的数据供继电器读取，继电器写入的数据被记录。
+// 读取支持读超时，写入从不阻塞。
+type FakeConn struct {
+	mu           sync.Mutex
+	in           []byte
+	writes       [][]byte
+	readDeadline time.Time
+	notify       chan struct{}
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+// NewFakeConn 创建模拟连接
+func NewFakeConn() *FakeConn {
+	return &FakeConn{
+		notify: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+// Feed 追加待读取的数据
+func (c *FakeConn) Feed(frames ...[]byte) {
+	c.mu.Lock()
+	for _, frame := range frames {
+		c.in = append(c.in, frame...)
+	}
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// FeedHex 追加 16 进制字符串表示的数据，如 "A0 10 01 AA ... A7"
+func (c *FakeConn) FeedHex(frames ...string) error {
+	for _, frame := range frames {
+		b, err := ParseHex(frame)
+		if err != nil {
+			return err
+		}
+		c.Feed(b)
+	}
+	return nil
+}
+
+// Written 所有写入数据
+func (c *FakeConn) Written() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Join(c.writes, nil)
+}
+
+// Writes 每次写入的数据
+func (c *FakeConn) Writes() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyWrites(c.writes)
+}
+
+// Read 读取 Feed 写入的数据，没有数据时阻塞到有数据、超时或关闭
+func (c *FakeConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if len(c.in) > 0 {
+			n := copy(b, c.in)
+			c.in = c.in[n:]
+			c.mu.Unlock()
+			return n, nil
+		}
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		if err := c.wait(deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// 等待新数据、超时或关闭
+func (c *FakeConn) wait(deadline time.Time) error {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return timeoutError{}
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-c.closed:
+		return io.EOF
+	case <-c.notify:
+		return nil
+	case <-timeout:
+		return timeoutError{}
+	}
+}
+
+// Write 记录写入数据
+func (c *FakeConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	c.mu.Lock()
+	c.writes = append(c.writes, append([]byte{}, b...))
+	c.mu.Unlock()
+	return len(b), nil
+}
+
+// Close 关闭连接，阻塞中的读取返回 io.EOF
+func (c *FakeConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+// LocalAddr 本地地址
+func (c *FakeConn) LocalAddr() net.Addr {
+	return fakeAddr{}
+}
+
+// RemoteAddr 远端地址
+func (c *FakeConn) RemoteAddr() net.Addr {
+	return fakeAddr{}
+}
+
+// SetDeadline 设置读写超时，写入从不阻塞，只有读超时生效
+func (c *FakeConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline 设置读超时
+func (c *FakeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetWriteDeadline 写入从不阻塞，忽略
+func (c *FakeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// RecordedConn 包装连接并记录所有写入数据
+type RecordedConn struct {
+	net.Conn
+	mu     sync.Mutex
+	writes [][]byte
+}
+
+// NewRecordedConn 创建记录写入的连接
+func NewRecordedConn(conn net.Conn) *RecordedConn {
+	return &RecordedConn{Conn: conn}
+}
+
+// Write 写入并记录
+func (c *RecordedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.mu.Lock()
+		c.writes = append(c.writes, append([]byte{}, b[:n]...))
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// Written 所有写入数据
+func (c *RecordedConn) Written() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Join(c.writes, nil)
+}
+
+// Writes 每次写入的数据
+func (c *RecordedConn) Writes() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyWrites(c.writes)
+}
+
+// ParseHex 解析空格分隔的 16 进制字符串
+func ParseHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse hex failed")
+	}
+	return b, nil
+}
+
+func copyWrites(writes [][]byte) [][]byte {
+	out := make([][]byte, len(writes))
+	for i, w := range writes {
+		out[i] = append([]byte{}, w...)
+	}
+	return out
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type fakeAddr struct{}
+
+func (fakeAddr) Network() string { return "fake" }
+func (fakeAddr) String() string  { return "fake" }
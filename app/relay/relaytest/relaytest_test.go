@@ -0,0 +1,97 @@
+package relaytest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFakeConn(t *testing.T) {
+	c := NewFakeConn()
+	if err := c.FeedHex("A0 10 01", "AA"); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(c, b); err != nil || !bytes.Equal(b, []byte{0xA0, 0x10, 0x01, 0xAA}) {
+		t.Fatalf("read % X, %v", b, err)
+	}
+
+	c.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := c.Read(b)
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("read past deadline: %v", err)
+	}
+	c.SetReadDeadline(time.Time{})
+
+	c.Write([]byte{1, 2})
+	c.Write([]byte{3})
+	if !bytes.Equal(c.Written(), []byte{1, 2, 3}) || len(c.Writes()) != 2 {
+		t.Fatalf("written % X", c.Written())
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Read(b)
+		done <- err
+	}()
+	c.Close()
+	if err := <-done; err != io.EOF {
+		t.Fatalf("read after close: %v", err)
+	}
+}
+
+func TestRecordedConn(t *testing.T) {
+	device, relay := NewPipe()
+	rc := NewRecordedConn(relay)
+	go io.Copy(ioutil.Discard, device)
+	defer device.Close()
+	rc.Write([]byte{0xA0, 0xA7})
+	if !bytes.Equal(rc.Written(), []byte{0xA0, 0xA7}) {
+		t.Fatalf("recorded % X", rc.Written())
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFakeClock(start)
+	late, early := c.NewTimer(2*time.Second), c.NewTimer(time.Second)
+	select {
+	case <-c.NewTimer(0).C():
+	default:
+		t.Fatal("zero duration not fired immediately")
+	}
+	c.Advance(time.Second)
+	if at := <-early.C(); !at.Equal(start.Add(time.Second)) {
+		t.Fatalf("early fired at %v", at)
+	}
+	if early.Stop() {
+		t.Fatal("fired timer stopped")
+	}
+	select {
+	case <-late.C():
+		t.Fatal("late fired early")
+	default:
+	}
+	if c.Waiters() != 1 {
+		t.Fatalf("waiters %d, want 1", c.Waiters())
+	}
+	c.Advance(time.Second)
+	<-late.C()
+	if !c.Now().Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("now %v", c.Now())
+	}
+	// 停止的定时器不再计入等待，也不会触发
+	stopped := c.NewTimer(time.Second)
+	if !stopped.Stop() || c.Waiters() != 0 {
+		t.Fatalf("stop: waiters %d", c.Waiters())
+	}
+	c.Advance(time.Second)
+	select {
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
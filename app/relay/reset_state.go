@@ -0,0 +1,25 @@
+package relay
+
+import "time"
+
+// ResetState 清空缓存的输出、输入、温湿度与模拟量状态，之后的读取视为上线后的第一次读取：
+// OnPropertyChange 将其作为从零值的变化触发，OnInputEdge 不触发，去抖与平滑重新开始。
+// 等待中的去抖提交被取消。可在 EventReconnect 后调用，避免沿用断线前的数据
+func (r *Relay) ResetState() {
+	r.debounce.mu.Lock()
+	defer r.debounce.mu.Unlock()
+	for route, p := range r.debounce.pending {
+		close(p.cancel)
+		delete(r.debounce.pending, route)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outputState = OutputStates{}
+	r.inputState = InputStates{}
+	r.th = TemperatureAndHumidity{}
+	r.rawTH = TemperatureAndHumidity{}
+	r.analog = nil
+	r.seen = make(map[PropertyType]bool)
+	r.raw = make(map[PropertyType][]byte)
+	r.updated = make(map[PropertyType]time.Time)
+}
@@ -11,13 +11,117 @@ import (
 
 // SaveOutputState 保存输出状态
 func (r *Relay) SaveOutputState(data []byte) {
+	r.saveFrame(data, decodeOutputState)
+}
+
+// SaveInputState 保存输入状态
+func (r *Relay) SaveInputState(data []byte) {
+	r.saveFrame(data, decodeInputState)
+}
+
+// SaveTH 保存温湿度状态
+func (r *Relay) SaveTH(data []byte) {
+	r.saveFrame(data, decodeTH)
+}
+
+// 解码并保存一帧数据
+func (r *Relay) saveFrame(frame []byte, decode func(frame []byte) (Property, error)) {
+	value, err := decode(frame)
+	if err != nil {
+		r.log().Printf("设备 %d %v", r.SubDeviceID, err)
+		r.reportError(err)
+		return
+	}
+	var t PropertyType
+	switch value.(type) {
+	case OutputStates:
+		t = OUTPUTSTATE
+	case InputStates:
+		t = INPUTSTATE
+	case TemperatureAndHumidity:
+		t = TH
+	}
+	r.store(Data{PropertyType: t, Data: value, Raw: append([]byte{}, frame...)})
+}
+
+// 保存解码后的属性，配置了 Debounce 时输入状态先去抖
+func (r *Relay) store(data Data) {
+	if states, ok := data.Data.(InputStates); ok && r.debounce.d > 0 {
+		r.debounce.mu.Lock()
+		defer r.debounce.mu.Unlock()
+		data.Data = r.debounceInputs(states)
+	}
+	r.commit(data)
+}
+
+// 写入属性并通知，值类型与属性类型不符时丢弃
+func (r *Relay) commit(data Data) {
+	if th, ok := data.Data.(TemperatureAndHumidity); ok {
+		data.Data = r.calibrate(th)
+	}
+	r.mu.Lock()
+	var old Property
+	switch value := data.Data.(type) {
+	case OutputStates:
+		old, r.outputState = r.outputState, value
+	case InputStates:
+		old, r.inputState = r.inputState, value
+	case Counters:
+		value = r.mergeCounters(value)
+		data.Data = value
+		old, r.counts = r.counts, value
+	case TemperatureAndHumidity:
+		r.rawTH = value
+		if r.seen[TH] {
+			value = r.smooth(r.th, value)
+			data.Data = value
+		}
+		old, r.th = r.th, value
+	default:
+		r.mu.Unlock()
+		r.reportError(errors.Errorf("store %s failed: unexpected value %T", data.PropertyType, data.Data))
+		return
+	}
+	if data.Time.IsZero() {
+		data.Time = r.now()
+	}
+	seen := r.seen[data.PropertyType]
+	r.seen[data.PropertyType] = true
+	r.raw[data.PropertyType] = data.Raw
+	r.updated[data.PropertyType] = data.Time
+	r.observeLatency(data.PropertyType, data.Time)
+	r.mu.Unlock()
+	// 分发的是副本，事件、通道与回调修改数据不影响已保存的状态
+	switch value := data.Data.(type) {
+	case OutputStates:
+		data.Data = append(OutputStates{}, value...)
+	case InputStates:
+		data.Data = append(InputStates{}, value...)
+	case Counters:
+		data.Data = append(Counters{}, value...)
+	}
+	r.emit(EventPropertyUpdate, Data{PropertyType: data.PropertyType, Data: data.Data, Raw: append([]byte{}, data.Raw...), Time: data.Time})
+	r.publish(Data{PropertyType: data.PropertyType, Data: data.Data, Raw: append([]byte{}, data.Raw...), Time: data.Time})
+	r.inFlight.release(data.PropertyType)
+	r.breakerReceived(data.PropertyType)
+	r.waiters.notify(data.PropertyType, data.Data)
+	r.persist(seen, old, data.Data)
+	r.propertyChanged(data.PropertyType, seen, old, data.Data)
+	if value, ok := data.Data.(InputStates); ok {
+		old, _ := old.(InputStates)
+		r.inputChanged(seen, old, value)
+	}
+}
+
+// 解码输出状态
+func decodeOutputState(data []byte) (Property, error) {
 	stateStr := utils.ByteToBinaryString(data[5]) // 二进制字符串
 	stateStrs := strings.Split(stateStr, "")      // 字符串数组
 	states, err := makeOutputStates(stateStrs)
 	if err != nil {
-		// TODO log
+		return nil, errors.Wrap(err, "decode output state failed")
 	}
-	r.outputState = states
+	return states, nil
 }
 
 func makeOutputStates(stateStrs []string) (OutputStates, error) {
@@ -35,15 +139,15 @@ func makeOutputStates(stateStrs []string) (OutputStates, error) {
 	return states, nil
 }
 
-// SaveInputState 保存输入状态
-func (r *Relay) SaveInputState(data []byte) {
+// 解码输入状态
+func decodeInputState(data []byte) (Property, error) {
 	stateStr := utils.ByteToBinaryString(data[4]) // 二进制字符串
 	stateStrs := strings.Split(stateStr, "")      // 字符串数组
 	states, err := makeInputStates(stateStrs)
 	if err != nil {
-		// TODO log
+		return nil, errors.Wrap(err, "decode input state failed")
 	}
-	r.inputState = states
+	return states, nil
 }
 
 func makeInputStates(stateStrs []string) (InputStates, error) {
@@ -61,23 +165,20 @@ func makeInputStates(stateStrs []string) (InputStates, error) {
 	return states, nil
 }
 
-// SaveTH 保存温湿度状态
-func (r *Relay) SaveTH(data []byte) {
+// 解码温湿度
+func decodeTH(data []byte) (Property, error) {
 	temperature, err := makeTemperature(data[4], data[5], data[6])
 	if err != nil {
-		fmt.Println(err)
-		// TODO log
+		return nil, err
 	}
 	humidity, err := makeHumidity(data[7], data[8])
 	if err != nil {
-		fmt.Println(err)
-		// TODO log
+		return nil, err
 	}
-	th := TemperatureAndHumidity{
+	return TemperatureAndHumidity{
 		Temperature: temperature,
 		Humidity:    humidity,
-	}
-	r.th = th
+	}, nil
 }
 
 func makeTemperature(positive byte, integer byte, decimal byte) (float64, error) {
@@ -0,0 +1,66 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Modbus 从站地址范围
+const (
+	minSlaveAddr = 1
+	maxSlaveAddr = 247
+)
+
+// DefaultScanTimeout ScanBus 每个地址的默认响应超时
+const DefaultScanTimeout = 200 * time.Millisecond
+
+// ScanBus 扫描总线上的 Modbus 从站：依次向地址 1~247 发送读保持寄存器 0 的请求，
+// 有响应（包括异常响应）的地址视为存在，按地址升序返回；每个地址等待 DefaultScanTimeout。
+// 扫描期间独占 conn，结束后不关闭；ctx 取消时返回已发现的地址与 ctx 的错误。
+// 默认协议的命令不带从站地址，返回 ErrNotModbus
+func ScanBus(ctx context.Context, conn net.Conn, framing FramingMode) ([]uint16, error) {
+	return ScanBusTimeout(ctx, conn, framing, DefaultScanTimeout)
+}
+
+// ScanBusTimeout 与 ScanBus 相同，每个地址等待 timeout，用于响应较慢的 RTU 线路；
+// timeout <= 0 时使用 DefaultScanTimeout
+func ScanBusTimeout(ctx context.Context, conn net.Conn, framing FramingMode, timeout time.Duration) ([]uint16, error) {
+	if framing == FramingVendor {
+		return nil, errors.Wrap(ErrNotModbus, "scan bus failed")
+	}
+	if timeout <= 0 {
+		timeout = DefaultScanTimeout
+	}
+	r := New(nil, conn, 0, Framing(framing), NotOwnConn(), PassiveMode(), WithLogger(nopLogger{}))
+	if err := r.ReadLoop(0); err != nil {
+		return nil, errors.Wrap(err, "scan bus failed")
+	}
+	defer func() {
+		r.Offline()
+		r.Wait()
+	}()
+	var found []uint16
+	for addr := uint16(minSlaveAddr); addr <= maxSlaveAddr; addr++ {
+		if err := ctx.Err(); err != nil {
+			return found, errors.Wrap(err, "scan bus failed")
+		}
+		if r.probe(ctx, addr, timeout) {
+			found = append(found, addr)
+		}
+	}
+	return found, nil
+}
+
+// 探测 addr 是否有从站响应
+func (r *Relay) probe(ctx context.Context, addr uint16, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	_, err := r.call(ctx, ReadHoldingRegistersFrame(addr, 0, 1))
+	if _, ok := err.(*ModbusException); ok {
+		return true
+	}
+	return err == nil
+}
@@ -0,0 +1,178 @@
+package relay
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler 共享的询问调度器，用一个协程按下次触发时间（最小堆）驱动多个继电器的 WriteFn，
+// 代替每个继电器每个 WriteFn 一个协程。
+// 触发时在临时协程中执行写入，上一次尚未完成时跳过本次；继电器下线时移除其任务。
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs jobHeap
+	wake chan struct{}
+	stop chan struct{}
+	once sync.Once
+}
+
+// WithScheduler 使用共享调度器发送询问，不再为每个 WriteFn 启动协程
+func WithScheduler(s *Scheduler) Option {
+	return func(r *Relay) {
+		r.scheduler = s
+	}
+}
+
+// NewScheduler 创建调度器，需调用 Run 启动
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+}
+
+// Run 开启调度协程
+func (s *Scheduler) Run() {
+	go s.loop()
+}
+
+// Stop 停止调度，已注册的任务不再触发
+func (s *Scheduler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Len 已注册的任务数
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.jobs)
+}
+
+// 注册任务，立即触发第一次，配置了 InquiryJitter 时随机推迟
+func (s *Scheduler) add(r *Relay, wf WriteFn) {
+	at := time.Now().Add(r.initialDelay(wf.delay()))
+	s.mu.Lock()
+	heap.Push(&s.jobs, &job{at: at, r: r, wf: wf})
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 继电器的任务立即触发，之后按新间隔重新排期
+func (s *Scheduler) reschedule(r *Relay) {
+	now := time.Now()
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		if j.r == r {
+			j.at = now
+		}
+	}
+	heap.Init(&s.jobs)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 移除继电器的全部任务，由下线调用
+func (s *Scheduler) remove(r *Relay) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := s.jobs[:0]
+	for _, j := range s.jobs {
+		if j.r != r {
+			jobs = append(jobs, j)
+		}
+	}
+	for i := len(jobs); i < len(s.jobs); i++ {
+		s.jobs[i] = nil
+	}
+	s.jobs = jobs
+	heap.Init(&s.jobs)
+}
+
+// 调度循环
+func (s *Scheduler) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		wait := time.Hour
+		if len(s.jobs) > 0 {
+			wait = time.Until(s.jobs[0].at)
+		}
+		s.mu.Unlock()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+			s.fire(time.Now())
+		}
+	}
+}
+
+// 触发所有到期任务并重新排期
+func (s *Scheduler) fire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.jobs) > 0 && !s.jobs[0].at.After(now) {
+		j := heap.Pop(&s.jobs).(*job)
+		if isClosed(j.r.closed) || j.r.ctx.Err() != nil {
+			continue
+		}
+		if atomic.CompareAndSwapInt32(&j.running, 0, 1) {
+			go j.run()
+		}
+		j.at = now.Add(j.r.jittered(j.wf.delay()))
+		heap.Push(&s.jobs, j)
+	}
+}
+
+// 调度任务
+type job struct {
+	at      time.Time
+	r       *Relay
+	wf      WriteFn
+	running int32
+}
+
+// 执行一次写入
+func (j *job) run() {
+	defer atomic.StoreInt32(&j.running, 0)
+	j.r.poll(j.wf)
+}
+
+// 按触发时间排序的最小堆
+type jobHeap []*job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h jobHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x interface{}) {
+	*h = append(*h, x.(*job))
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return j
+}
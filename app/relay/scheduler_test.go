@@ -0,0 +1,78 @@
+package relay
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"relay/app/relay/relaytest"
+)
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler()
+	s.Run()
+	defer s.Stop()
+	r := New(nil, relaytest.NewFakeConn(), 0x1001, KeepAlive(time.Second), WithScheduler(s))
+	var fast, slow int32
+	wfs := []WriteFn{
+		{d: 5 * time.Millisecond, fn: func() error { atomic.AddInt32(&fast, 1); return nil }},
+		{d: time.Hour, fn: func() error { atomic.AddInt32(&slow, 1); return nil }},
+	}
+	if err := r.WriteLoop(wfs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&fast); n < 3 {
+		t.Fatalf("fast job fired %d times", n)
+	}
+	if n := atomic.LoadInt32(&slow); n != 1 {
+		t.Fatalf("slow job fired %d times", n)
+	}
+	r.Offline()
+	if n := s.Len(); n != 0 {
+		t.Fatalf("%d jobs left after offline", n)
+	}
+}
+
+const benchRelays = 10000
+
+func benchmarkGoroutines(b *testing.B, opts func() []Option) {
+	for i := 0; i < b.N; i++ {
+		before := runtime.NumGoroutine()
+		relays := make([]*Relay, benchRelays)
+		for j := range relays {
+			relays[j] = New(nil, relaytest.NewFakeConn(), uint16(j), append(opts(), KeepAlive(time.Hour))...)
+			wfs := []WriteFn{{d: time.Hour, fn: relays[j].InquiryTH}, {d: time.Hour, fn: relays[j].InquiryInputState}}
+			if err := relays[j].WriteLoop(wfs); err != nil {
+				b.Fatal(err)
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		b.ReportMetric(float64(runtime.NumGoroutine()-before), "goroutines")
+		for _, r := range relays {
+			r.Offline()
+		}
+	}
+}
+
+func BenchmarkWriteLoopGoroutines(b *testing.B) {
+	benchmarkGoroutines(b, func() []Option {
+		return []Option{WithLogger(nopLogger{})}
+	})
+}
+
+func BenchmarkSchedulerGoroutines(b *testing.B) {
+	s := NewScheduler()
+	s.Run()
+	defer s.Stop()
+	benchmarkGoroutines(b, func() []Option {
+		return []Option{WithLogger(nopLogger{}), WithScheduler(s)}
+	})
+}
+
+// 下线并等待后台协程退出，避免测试结束后协程仍在写日志
+func stop(r *Relay) {
+	r.Offline()
+	r.Wait()
+}
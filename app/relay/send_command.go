@@ -1,21 +1,95 @@
 package relay
 
 import (
+	"context"
 	"encoding/hex"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// WriteTimeout 写超时配置，默认不超时。
+// 超时的写入与其他写入失败一样作为非致命错误发送到 Errors，不会阻塞写循环。
+func WriteTimeout(d time.Duration) Option {
+	return func(r *Relay) {
+		r.writeTimeout = d
+	}
+}
+
 // 发送命令
-func (r *Relay) sendCommand(cmd string) error {
+func (r *Relay) sendCommand(ctx context.Context, cmd string) error {
 	cmdByte, err := commandFormatter(cmd)
 	if err != nil {
 		return errors.Wrap(err, "send command failed")
 	}
-	if _, err = r.Conn.Write(cmdByte); err != nil {
-		return errors.Wrap(err, "send command failed")
+	return r.WriteFrameContext(ctx, cmdByte)
+}
+
+// WriteFrame 向连接写入一帧原始数据，可配合 Modbus 帧构造函数发送自定义请求
+func (r *Relay) WriteFrame(frame []byte) error {
+	return r.WriteFrameContext(context.Background(), frame)
+}
+
+// WriteFrameContext 向连接写入一帧原始数据，ctx 的截止时间与 WriteTimeout 取较早者，
+// ctx 取消时中断阻塞中的写入；写入前先经过 WriteMiddlewares。
+// 同一连接上的写入依次进行，等待其他写入时 ctx 结束返回 ctx 的错误
+func (r *Relay) WriteFrameContext(ctx context.Context, frame []byte) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "write frame failed")
+	}
+	frame, err := r.processWrite(frame)
+	if err != nil {
+		return errors.Wrap(err, "write frame failed")
+	}
+	select {
+	case r.writeLock <- struct{}{}:
+		defer func() { <-r.writeLock }()
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "write frame failed")
+	}
+	conn := r.conn()
+	if conn == nil {
+		return errors.Wrap(ErrNotConnected, "write frame failed")
+	}
+	var deadline time.Time
+	if r.writeTimeout > 0 {
+		deadline = time.Now().Add(r.writeTimeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	if !deadline.IsZero() {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return errors.Wrap(err, "write frame failed")
+		}
+	}
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			select {
+			case <-ctx.Done():
+				conn.SetWriteDeadline(time.Now())
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-exited
+			conn.SetWriteDeadline(time.Time{})
+		}()
+	}
+	if _, err := conn.Write(frame); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		err = errors.Wrap(err, "write frame failed")
+		r.reportError(err)
+		return err
 	}
+	count(&r.counters.framesWritten)
 	return nil
 }
 
@@ -0,0 +1,88 @@
+package relay
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SequenceNumber 帧序号配置，用于发现丢帧。extract 从帧中取出序号，帧不带序号时返回 false；
+// 序号每帧加一，到 max 后回到 0，max 为 0 时按 32 位回绕。
+// 序号不连续时发送 *SequenceGapError 到 Errors 通道，并计入 Stats 的 SequenceGaps 与 FramesMissed。
+// 默认协议没有序号，需按设备协议提供 extract
+func SequenceNumber(extract func(frame []byte) (uint32, bool), max uint32) Option {
+	return func(r *Relay) {
+		r.sequence = &sequence{extract: extract, max: max}
+	}
+}
+
+// SequenceGapError 帧序号不连续
+type SequenceGapError struct {
+	Expected uint32 // 期望的序号
+	Got      uint32 // 收到的序号
+	Missed   uint32 // 按序号推算丢失的帧数
+}
+
+func (e *SequenceGapError) Error() string {
+	return fmt.Sprintf("sequence gap: expected %d, got %d, %d frames missed", e.Expected, e.Got, e.Missed)
+}
+
+// 帧序号跟踪
+type sequence struct {
+	extract func(frame []byte) (uint32, bool)
+	max     uint32
+
+	mu   sync.Mutex
+	last uint32
+	seen bool
+}
+
+// 检查帧序号，不连续时返回 *SequenceGapError；第一帧只记录序号
+func (s *sequence) check(frame []byte) *SequenceGapError {
+	seq, ok := s.extract(frame)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, seen := s.last, s.seen
+	s.last, s.seen = seq, true
+	if !seen {
+		return nil
+	}
+	expected := s.next(last)
+	if seq == expected {
+		return nil
+	}
+	return &SequenceGapError{Expected: expected, Got: seq, Missed: s.distance(expected, seq)}
+}
+
+// 下一个序号
+func (s *sequence) next(seq uint32) uint32 {
+	if s.max != 0 && seq >= s.max {
+		return 0
+	}
+	return seq + 1
+}
+
+// 从 from 向前到 to 的距离
+func (s *sequence) distance(from, to uint32) uint32 {
+	if s.max == 0 || to >= from {
+		return to - from
+	}
+	return s.max - from + 1 + to
+}
+
+// 检查帧序号并统计
+func (r *Relay) checkSequence(frame []byte) {
+	if r.sequence == nil {
+		return
+	}
+	gap := r.sequence.check(frame)
+	if gap == nil {
+		return
+	}
+	count(&r.counters.sequenceGaps)
+	addCount(&r.counters.framesMissed, uint64(gap.Missed))
+	r.log().Printf("设备 %d %v", r.SubDeviceID, gap)
+	r.reportError(gap)
+}
@@ -0,0 +1,31 @@
+package relay
+
+import (
+	"testing"
+)
+
+func TestSequenceNumber(t *testing.T) {
+	// 序号位于 data[11]，到 255 后回到 0
+	extract := func(frame []byte) (uint32, bool) {
+		return uint32(frame[11]), true
+	}
+	r := New(nil, nil, 0x1001, SequenceNumber(extract, 255), WithLogger(nopLogger{}))
+	frame, _ := commandFormatter(testFrames[1])
+	for _, seq := range []byte{254, 255, 0, 3, 4} {
+		frame[11] = seq
+		r.handleFrame(frame)
+	}
+	stats := r.Stats()
+	if stats.SequenceGaps != 1 || stats.FramesMissed != 2 {
+		t.Fatalf("gaps %d missed %d, want 1 and 2", stats.SequenceGaps, stats.FramesMissed)
+	}
+	err := <-r.Errors()
+	if gap, ok := err.(*SequenceGapError); !ok || gap.Expected != 1 || gap.Got != 3 {
+		t.Fatalf("error %v", err)
+	}
+
+	s := &sequence{max: 9}
+	if d := s.distance(8, 1); d != 3 {
+		t.Fatalf("wrapped distance %d, want 3", d)
+	}
+}
@@ -1,24 +1,140 @@
 package relay
 
 import (
+	"context"
 	"relay/pkg/utils"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
+// OutputRoutes 输出路数
+const OutputRoutes = 8
+
 // SetState 设置状态
-func (r *Relay) SetState(state StateCMDType, nos ...uint8) {
+func (r *Relay) SetState(state StateCMDType, nos ...uint8) error {
+	return r.SetStateContext(context.Background(), state, nos...)
+}
+
+// SetStateContext 设置状态，ctx 取消时中断写入
+func (r *Relay) SetStateContext(ctx context.Context, state StateCMDType, nos ...uint8) error {
+	cmd, err := stateCommand(state, nos...)
+	if err != nil {
+		return errors.Wrap(err, "set state failed")
+	}
+	return r.sendCommand(ctx, cmd)
+}
+
+// SetOutput 设置单路输出，value 为 1 闭合、0 断开，写入成功后更新输出状态
+func (r *Relay) SetOutput(route uint8, value uint8) error {
+	return r.SetOutputContext(context.Background(), route, value)
+}
+
+// SetOutputContext 设置单路输出，ctx 取消时中断写入或确认等待
+func (r *Relay) SetOutputContext(ctx context.Context, route uint8, value uint8) error {
+	return r.SetOutputsContext(ctx, OutputStates{{Route: route, Value: value}})
+}
+
+// SetOutputs 批量设置输出，相同状态的路数合并为一帧发送，全部写入成功后才更新输出状态。
+// 配置 Modbus 分帧时用写多个线圈（功能码 0F）一帧写入全部路数，设备不支持时逐路写入
+func (r *Relay) SetOutputs(states OutputStates) error {
+	return r.SetOutputsContext(context.Background(), states)
+}
+
+// SetOutputsContext 批量设置输出，ctx 取消时中断写入或确认等待，此时不更新输出状态
+func (r *Relay) SetOutputsContext(ctx context.Context, states OutputStates) error {
+	routes := map[StateCMDType][]uint8{}
+	values := map[uint8]uint8{}
+	for _, state := range states {
+		if err := checkOutput(state.Route, state.Value); err != nil {
+			return errors.Wrap(err, "set outputs failed")
+		}
+		values[state.Route] = state.Value
+	}
+	if r.framing != FramingVendor {
+		if err := r.writeCoils(ctx, r.SubDeviceID, values, r.OutputState()); err != nil {
+			return errors.Wrap(err, "set outputs failed")
+		}
+		if r.verifyTimeout > 0 {
+			if err := r.confirmCoils(ctx, r.SubDeviceID, values); err != nil {
+				return errors.Wrap(err, "set outputs failed")
+			}
+		}
+		r.updateOutputs(values)
+		return nil
+	}
+	for route, value := range values {
+		cmdType := outputCMDType(value)
+		routes[cmdType] = append(routes[cmdType], route)
+	}
+	var confirm chan Property
+	if r.verifyTimeout > 0 {
+		confirm = r.waiters.add(OUTPUTSTATE)
+		defer r.waiters.remove(OUTPUTSTATE, confirm)
+	}
+	for _, cmdType := range []StateCMDType{ON, OFF} {
+		if len(routes[cmdType]) == 0 {
+			continue
+		}
+		if err := r.SetStateContext(ctx, cmdType, routes[cmdType]...); err != nil {
+			return errors.Wrap(err, "set outputs failed")
+		}
+	}
+	if confirm != nil {
+		if err := r.confirmOutputs(ctx, confirm, values); err != nil {
+			return errors.Wrap(err, "set outputs failed")
+		}
+	}
+	r.updateOutputs(values)
+	return nil
+}
+
+// 写入成功后更新输出状态
+func (r *Relay) updateOutputs(values map[uint8]uint8) {
+	r.mu.Lock()
+	next := r.outputState
+	for route, value := range values {
+		next = next.Set(route, value)
+	}
+	r.outputState = next
+	r.mu.Unlock()
+}
+
+// 校验输出路数与值
+func checkOutput(route uint8, value uint8) error {
+	if route < 1 || route > OutputRoutes {
+		return errors.Errorf("route %d out of range [1, %d]", route, OutputRoutes)
+	}
+	if value > 1 {
+		return errors.Errorf("invalid output value %d", value)
+	}
+	return nil
+}
+
+// 输出值对应的控制命令
+func outputCMDType(value uint8) StateCMDType {
+	if value == 1 {
+		return ON
+	}
+	return OFF
+}
+
+// 生成设置状态命令
+func stateCommand(state StateCMDType, nos ...uint8) (string, error) {
 	no, err := getNoHex(nos...)
 	if err != nil {
-		// log
+		return "", err
 	}
-	cmd := "A0 01 08 2B 00 " + no + " " + string(state) + " 00 00 00 00 00 A7"
-	r.sendCommand(cmd)
+	return "A0 01 08 2B 00 " + no + " " + string(state) + " 00 00 00 00 00 A7", nil
 }
 
 // 获取选择编号 16 进制字符串
 func getNoHex(nos ...uint8) (string, error) {
 	stateArr := strings.Split("00000000", "")
 	for _, no := range nos {
+		if no < 1 || no > OutputRoutes {
+			return "", errors.Errorf("route %d out of range [1, %d]", no, OutputRoutes)
+		}
 		stateArr[7-(no-1)] = "1"
 	}
 	stateStr := strings.Join(stateArr, "")
@@ -0,0 +1,161 @@
+package relay
+
+import (
+	"math"
+	"net"
+	"sync"
+)
+
+// SimConn 模拟设备连接，按默认协议响应询问与设置命令，用于测试和演示。
+// 温湿度、输入询问回复当前值，设置命令更新输出并回复输出状态帧。
+type SimConn struct {
+	net.Conn
+
+	subDeviceID uint16
+	device      net.Conn
+	replies     chan []byte
+
+	mu      sync.Mutex
+	closed  bool
+	outputs uint8
+	inputs  uint8
+	th      TemperatureAndHumidity
+}
+
+// 待发送回复的队列长度，队列满时丢弃
+const simReplyQueueSize = 64
+
+// NewSimConn 创建模拟设备连接，subDeviceID 为回复帧中的设备号
+func NewSimConn(subDeviceID uint16) *SimConn {
+	conn, device := net.Pipe()
+	s := &SimConn{
+		Conn:        conn,
+		subDeviceID: subDeviceID,
+		device:      device,
+		replies:     make(chan []byte, simReplyQueueSize),
+	}
+	go s.serve()
+	go s.reply()
+	return s
+}
+
+// SetInputs 设置输入状态，bit0 为第 1 路
+func (s *SimConn) SetInputs(mask uint8) {
+	s.mu.Lock()
+	s.inputs = mask
+	s.mu.Unlock()
+}
+
+// SetTH 设置温湿度，保留一位小数
+func (s *SimConn) SetTH(th TemperatureAndHumidity) {
+	s.mu.Lock()
+	s.th = th
+	s.mu.Unlock()
+}
+
+// Outputs 当前输出状态，bit0 为第 1 路
+func (s *SimConn) Outputs() uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outputs
+}
+
+// Report 主动上报一次属性，输出状态没有询问命令，可用此方法模拟设备上报
+func (s *SimConn) Report(t PropertyType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.send(s.frame(t))
+}
+
+// Close 关闭连接，模拟设备随之停止
+func (s *SimConn) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.replies)
+	}
+	s.mu.Unlock()
+	s.device.Close()
+	return s.Conn.Close()
+}
+
+// 读取并响应命令
+func (s *SimConn) serve() {
+	reader := newFrameReader(DefaultFrameLength, verifyEnvelope)
+	for {
+		frame, _, err := reader.next(s.device)
+		if _, ok := err.(*frameError); ok {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		s.handle(frame)
+	}
+}
+
+// 发送回复，relay 未读取时不阻塞读取命令
+func (s *SimConn) reply() {
+	for frame := range s.replies {
+		if _, err := s.device.Write(frame); err != nil {
+			return
+		}
+	}
+}
+
+// 响应一条命令
+func (s *SimConn) handle(frame []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch frame[3] {
+	case 0x2A:
+		s.send(s.frame(TH))
+	case 0x1B:
+		s.send(s.frame(INPUTSTATE))
+	case 0x2B:
+		if frame[6] == 0x01 {
+			s.outputs |= frame[5]
+		} else {
+			s.outputs &^= frame[5]
+		}
+		s.send(s.frame(OUTPUTSTATE))
+	}
+}
+
+// 放入回复队列，需持有 mu
+func (s *SimConn) send(frame []byte) {
+	if s.closed {
+		return
+	}
+	select {
+	case s.replies <- frame:
+	default:
+	}
+}
+
+// 生成属性帧，需持有 mu
+func (s *SimConn) frame(t PropertyType) []byte {
+	frame := make([]byte, DefaultFrameLength)
+	frame[0], frame[1], frame[2], frame[len(frame)-1] = frameHead, byte(s.subDeviceID>>8), byte(s.subDeviceID), frameTail
+	switch t {
+	case OUTPUTSTATE:
+		frame[3], frame[5] = 0xAA, s.outputs
+	case INPUTSTATE:
+		frame[3], frame[4] = 0x1B, s.inputs
+	case TH:
+		frame[3] = 0x2A
+		frame[4] = 1
+		if s.th.Temperature < 0 {
+			frame[4] = 0
+		}
+		frame[5], frame[6] = splitDecimal(math.Abs(s.th.Temperature))
+		frame[7], frame[8] = splitDecimal(s.th.Humidity)
+	}
+	return frame
+}
+
+// 拆分整数与一位小数
+func splitDecimal(v float64) (byte, byte) {
+	tenths := int(math.Round(v * 10))
+	return byte(tenths / 10), byte(tenths % 10)
+}
@@ -0,0 +1,52 @@
+package relay
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimConn(t *testing.T) {
+	sim := NewSimConn(0x1001)
+	sim.SetInputs(0x05)
+	sim.SetTH(TemperatureAndHumidity{Temperature: -3.5, Humidity: 41.2})
+	r := New(nil, sim, 0x1001, KeepAlive(time.Hour))
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	th, err := r.QueryTH(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Temperature != -3.5 || th.Humidity != 41.2 {
+		t.Fatalf("th %+v", th)
+	}
+	inputs, err := r.QueryInputState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := 0
+	for _, state := range inputs {
+		if state.Value == 1 && (state.Route == 1 || state.Route == 3) {
+			on++
+		}
+	}
+	if on != 2 {
+		t.Fatalf("inputs %v", inputs)
+	}
+
+	if err := r.SetOutputs(OutputStates{{Route: 2, Value: 1}, {Route: 8, Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for sim.Outputs() != 0x82 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sim outputs %02X", sim.Outputs())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
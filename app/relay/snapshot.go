@@ -0,0 +1,39 @@
+package relay
+
+import (
+	"time"
+)
+
+// Snapshot 继电器状态快照
+type Snapshot struct {
+	SubDeviceID uint16                 `json:"subDeviceId"`
+	OnlineTime  time.Time              `json:"onlineTime"`
+	Outputs     OutputStates           `json:"outputs"`
+	Inputs      InputStates            `json:"inputs"`
+	TH          TemperatureAndHumidity `json:"th"`
+	RawTH       TemperatureAndHumidity `json:"rawTh"` // 平滑前的温湿度，未配置 SmoothTH 时与 TH 相同
+
+	Analog []AnalogInput `json:"analog,omitempty"` // 模拟量输入，需配置 AnalogInputs 或调用 QueryAnalogInputs
+
+	OutputLabels map[uint8]string `json:"outputLabels,omitempty"`
+	InputLabels  map[uint8]string `json:"inputLabels,omitempty"`
+}
+
+// Snapshot 获取状态快照，各状态在同一把锁下读取，保证一致
+func (r *Relay) Snapshot() Snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return Snapshot{
+		SubDeviceID: r.SubDeviceID,
+		OnlineTime:  r.OnlineTime,
+		Outputs:     append(OutputStates{}, r.outputState...),
+		Inputs:      append(InputStates{}, r.inputState...),
+		TH:          r.th,
+		RawTH:       r.rawTH,
+
+		Analog: append([]AnalogInput(nil), r.analog...),
+
+		OutputLabels: copyLabels(r.outputLabels),
+		InputLabels:  copyLabels(r.inputLabels),
+	}
+}
@@ -0,0 +1,36 @@
+package relay
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotJSON(t *testing.T) {
+	r := New(nil, nil, 0x1001)
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	b, err := json.Marshal(r.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"subDeviceId":4097`, `"outputs":[]`, `"th":{"temperature":25.5,"humidity":60.2}`} {
+		if !strings.Contains(string(b), key) {
+			t.Fatalf("snapshot %s missing %s", b, key)
+		}
+	}
+}
+
+func TestSnapshotLabels(t *testing.T) {
+	r := New(nil, nil, 0x1001, Labels(map[uint8]string{3: "Garage Door"}, nil))
+	b, err := json.Marshal(r.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"outputLabels":{"3":"Garage Door"}`) || strings.Contains(string(b), "inputLabels") {
+		t.Fatalf("snapshot %s", b)
+	}
+	if r.OutputLabel(3) != "Garage Door" || r.InputLabel(1) != "" {
+		t.Fatal("unexpected labels")
+	}
+}
@@ -1,5 +1,9 @@
 package relay
 
+import (
+	"github.com/pkg/errors"
+)
+
 // PropertyType 属性类型枚举
 type PropertyType string
 
@@ -12,6 +16,49 @@ const TH PropertyType = "TH"
 // INPUTSTATE 输入状态标识符
 const INPUTSTATE PropertyType = "INPUTSTATE"
 
+// COUNTERS 脉冲计数标识符，需要配置 CounterRegisters
+const COUNTERS PropertyType = "COUNTERS"
+
+// 属性类型别名
+const (
+	PropertyOutput = OUTPUTSTATE
+	PropertyInput  = INPUTSTATE
+	PropertyTH     = TH
+)
+
+// String 属性类型标识符，用于日志输出
+func (p PropertyType) String() string {
+	return string(p)
+}
+
+// 默认编码可发送到 Instance 的属性类型
+var defaultEncodedTypes = map[PropertyType]bool{
+	OUTPUTSTATE: true,
+	INPUTSTATE:  true,
+	TH:          true,
+	COUNTERS:    true,
+}
+
+// 去重并校验属性类型，保持原有顺序，未注册获取方法的属性类型返回错误；
+// 默认编码不支持的属性类型需要配置 PropertySink 或 PropertyEncoder，否则返回错误
+func (r *Relay) uniqueStateTypes(types []PropertyType) ([]PropertyType, error) {
+	seen := make(map[PropertyType]bool, len(types))
+	unique := make([]PropertyType, 0, len(types))
+	for _, t := range types {
+		if _, ok := r.getters[t]; !ok {
+			return nil, errors.Errorf("unknown property type %q", t)
+		}
+		if !defaultEncodedTypes[t] && r.sink == nil && r.encoder == nil {
+			return nil, errors.Errorf("property type %q has no default encoding, configure PropertySink or PropertyEncoder", t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique, nil
+}
+
 // DefaultStateTypes 默认类型列表
 var DefaultStateTypes = []PropertyType{
 	OUTPUTSTATE, TH, INPUTSTATE,
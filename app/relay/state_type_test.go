@@ -0,0 +1,60 @@
+package relay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPropertyTypeString(t *testing.T) {
+	cases := map[PropertyType]string{
+		PropertyOutput: "OUTPUTSTATE",
+		PropertyInput:  "INPUTSTATE",
+		PropertyTH:     "TH",
+	}
+	for p, want := range cases {
+		if got := fmt.Sprint(p); got != want {
+			t.Errorf("%q.String() = %q", want, got)
+		}
+	}
+}
+
+func TestUniqueStateTypes(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second))
+	got, err := r.uniqueStateTypes([]PropertyType{TH, OUTPUTSTATE, TH})
+	if err != nil || len(got) != 2 || got[0] != TH || got[1] != OUTPUTSTATE {
+		t.Fatalf("unique %v, %v", got, err)
+	}
+	if _, err := r.uniqueStateTypes([]PropertyType{"OUTPUT"}); err == nil {
+		t.Fatal("unknown property type accepted")
+	}
+	if err := r.Online([]PropertyType{"TEMP"}); err == nil {
+		t.Fatal("Online accepted unknown property type")
+	}
+}
+
+func TestGetProperty(t *testing.T) {
+	const voltage PropertyType = "VOLTAGE"
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second), RegisterProperty(voltage, func() Property {
+		return 12.5
+	}))
+	if v, err := r.GetProperty(voltage); err != nil || v != 12.5 {
+		t.Fatalf("custom property %v, %v", v, err)
+	}
+	if v, err := r.GetProperty(TH); err != nil || v != (TemperatureAndHumidity{}) {
+		t.Fatalf("builtin property %v, %v", v, err)
+	}
+	if _, err := r.GetProperty("CURRENT"); err == nil {
+		t.Fatal("unregistered property returned")
+	}
+	// 默认编码不支持自定义属性，没有 PropertySink 或 PropertyEncoder 时不能上线
+	if _, err := r.uniqueStateTypes([]PropertyType{voltage}); err == nil {
+		t.Fatal("accepted property without encoding")
+	}
+	sunk := New(nil, nil, 0x1001, KeepAlive(time.Second), RegisterProperty(voltage, func() Property {
+		return 12.5
+	}), PropertySink(func(PropertyType, Property) {}))
+	if _, err := sunk.uniqueStateTypes([]PropertyType{voltage}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"sort"
+)
+
+// ByRoute 获取指定路数的输出状态
+func (s OutputStates) ByRoute(route uint8) (OutputState, bool) {
+	for _, state := range s {
+		if state.Route == route {
+			return state, true
+		}
+	}
+	return OutputState{}, false
+}
+
+// Set 返回设置了指定路数的值的副本，路数不存在时追加，不修改 s
+func (s OutputStates) Set(route, value uint8) OutputStates {
+	next := append(OutputStates{}, s...)
+	for i := range next {
+		if next[i].Route == route {
+			next[i].Value = value
+			return next
+		}
+	}
+	return append(next, OutputState{Route: route, Value: value})
+}
+
+// Map 转换为路数到值的映射
+func (s OutputStates) Map() map[uint8]uint8 {
+	m := make(map[uint8]uint8, len(s))
+	for _, state := range s {
+		m[state.Route] = state.Value
+	}
+	return m
+}
+
+// ByRoute 获取指定路数的输入状态
+func (s InputStates) ByRoute(route uint8) (InputState, bool) {
+	for _, state := range s {
+		if state.Route == route {
+			return state, true
+		}
+	}
+	return InputState{}, false
+}
+
+// Set 返回设置了指定路数的值的副本，路数不存在时追加，不修改 s
+func (s InputStates) Set(route, value uint8) InputStates {
+	next := append(InputStates{}, s...)
+	for i := range next {
+		if next[i].Route == route {
+			next[i].Value = value
+			return next
+		}
+	}
+	return append(next, InputState{Route: route, Value: value})
+}
+
+// Map 转换为路数到值的映射
+func (s InputStates) Map() map[uint8]uint8 {
+	m := make(map[uint8]uint8, len(s))
+	for _, state := range s {
+		m[state.Route] = state.Value
+	}
+	return m
+}
+
+// Equal 按路数比较，与顺序无关；缺少的路数视为 0，重复的路数以最后一项为准
+func (s OutputStates) Equal(other OutputStates) bool {
+	return len(diffRoutes(s.Map(), other.Map())) == 0
+}
+
+// Diff 按路数比较，返回 other 中与 s 值不同的路数及其在 other 中的值，按路数排序；
+// 缺少的路数视为 0，重复的路数以最后一项为准
+func (s OutputStates) Diff(other OutputStates) []OutputState {
+	now := other.Map()
+	var diff []OutputState
+	for _, route := range diffRoutes(s.Map(), now) {
+		diff = append(diff, OutputState{Route: route, Value: now[route]})
+	}
+	return diff
+}
+
+// Equal 按路数比较，与顺序无关；缺少的路数视为 0，重复的路数以最后一项为准
+func (s InputStates) Equal(other InputStates) bool {
+	return len(diffRoutes(s.Map(), other.Map())) == 0
+}
+
+// Diff 按路数比较，返回 other 中与 s 值不同的路数及其在 other 中的值，按路数排序；
+// 缺少的路数视为 0，重复的路数以最后一项为准
+func (s InputStates) Diff(other InputStates) []InputState {
+	now := other.Map()
+	var diff []InputState
+	for _, route := range diffRoutes(s.Map(), now) {
+		diff = append(diff, InputState{Route: route, Value: now[route]})
+	}
+	return diff
+}
+
+// 值不同的路数，按路数排序
+func diffRoutes(old, new map[uint8]uint8) []uint8 {
+	var routes []uint8
+	for route, value := range new {
+		if old[route] != value {
+			routes = append(routes, route)
+		}
+	}
+	for route, value := range old {
+		if _, ok := new[route]; !ok && value != 0 {
+			routes = append(routes, route)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i] < routes[j] })
+	return routes
+}
@@ -0,0 +1,57 @@
+package relay
+
+import (
+	"testing"
+)
+
+func TestStatesHelpers(t *testing.T) {
+	outputs := OutputStates{{Route: 2, Value: 1}, {Route: 1, Value: 0}}
+	if s, ok := outputs.ByRoute(2); !ok || s.Value != 1 {
+		t.Fatalf("ByRoute(2) = %+v, %v", s, ok)
+	}
+	if _, ok := outputs.ByRoute(3); ok {
+		t.Fatal("ByRoute(3) found missing route")
+	}
+	next := outputs.Set(1, 1).Set(3, 1)
+	if m := next.Map(); len(m) != 3 || m[1] != 1 || m[3] != 1 {
+		t.Fatalf("Set = %v", next)
+	}
+	if outputs[1].Value != 0 || len(outputs) != 2 {
+		t.Fatalf("Set modified receiver: %v", outputs)
+	}
+
+	inputs := InputStates{{Route: 1, Value: 1}}
+	if s, ok := inputs.Set(1, 0).ByRoute(1); !ok || s.Value != 0 {
+		t.Fatalf("input Set/ByRoute = %+v, %v", s, ok)
+	}
+	if m := inputs.Map(); m[1] != 1 {
+		t.Fatalf("input Map = %v", m)
+	}
+}
+
+func TestStatesDiff(t *testing.T) {
+	old := OutputStates{{Route: 1, Value: 1}, {Route: 2, Value: 0}, {Route: 3, Value: 1}}
+	reordered := OutputStates{{Route: 3, Value: 1}, {Route: 1, Value: 1}, {Route: 2, Value: 0}}
+	if !old.Equal(reordered) || len(old.Diff(reordered)) != 0 {
+		t.Fatal("reordered states not equal")
+	}
+	// 路数 2 缺少视为 0，路数 3 缺少视为从 1 变为 0，路数 4 新增，路数 1 重复以最后一项为准
+	next := OutputStates{{Route: 1, Value: 1}, {Route: 4, Value: 1}, {Route: 1, Value: 0}}
+	diff := old.Diff(next)
+	want := []OutputState{{Route: 1, Value: 0}, {Route: 3, Value: 0}, {Route: 4, Value: 1}}
+	if len(diff) != len(want) {
+		t.Fatalf("Diff = %v, want %v", diff, want)
+	}
+	for i := range want {
+		if diff[i] != want[i] {
+			t.Fatalf("Diff = %v, want %v", diff, want)
+		}
+	}
+	if old.Equal(next) {
+		t.Fatal("different states equal")
+	}
+	inputs := InputStates{{Route: 1, Value: 0}}
+	if d := inputs.Diff(InputStates{{Route: 1, Value: 1}}); len(d) != 1 || d[0].Value != 1 {
+		t.Fatalf("input Diff = %v", d)
+	}
+}
@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats 运行统计快照
+type Stats struct {
+	FramesRead    uint64        `json:"framesRead"`    // 读取的有效帧数
+	FramesWritten uint64        `json:"framesWritten"` // 写入的帧数
+	CRCFailures   uint64        `json:"crcFailures"`   // 校验失败的帧数
+	Reconnects    uint64        `json:"reconnects"`    // 重连成功次数
+	LastSeen      time.Time     `json:"lastSeen"`      // 最近一次收到有效帧的时间
+	Latency       time.Duration `json:"latency"`       // 最近一次查询往返时间，需使用 TimestampMiddleware
+	PostsDropped  uint64        `json:"postsDropped"`  // 发送队列满时丢弃的属性数
+	SequenceGaps  uint64        `json:"sequenceGaps"`  // 帧序号不连续的次数，需配置 SequenceNumber
+	FramesMissed  uint64        `json:"framesMissed"`  // 按帧序号推算丢失的帧数
+	DataDropped   uint64        `json:"dataDropped"`   // Data 通道满时丢弃的数据数
+	PollInterval  time.Duration `json:"pollInterval"`  // 当前询问间隔，配置 AdaptivePolling 时随响应时间变化
+}
+
+// 原子计数器
+type counters struct {
+	framesRead    uint64
+	framesWritten uint64
+	crcFailures   uint64
+	reconnects    uint64
+	postsDropped  uint64
+	sequenceGaps  uint64
+	framesMissed  uint64
+	dataDropped   uint64
+	latency       int64
+}
+
+// Stats 获取运行统计，计数器为原子操作，可在任意协程调用
+func (r *Relay) Stats() Stats {
+	return Stats{
+		FramesRead:    atomic.LoadUint64(&r.counters.framesRead),
+		FramesWritten: atomic.LoadUint64(&r.counters.framesWritten),
+		CRCFailures:   atomic.LoadUint64(&r.counters.crcFailures),
+		Reconnects:    atomic.LoadUint64(&r.counters.reconnects),
+		LastSeen:      r.LastSeen(),
+		Latency:       time.Duration(atomic.LoadInt64(&r.counters.latency)),
+		PostsDropped:  atomic.LoadUint64(&r.counters.postsDropped),
+		SequenceGaps:  atomic.LoadUint64(&r.counters.sequenceGaps),
+		FramesMissed:  atomic.LoadUint64(&r.counters.framesMissed),
+		DataDropped:   atomic.LoadUint64(&r.counters.dataDropped),
+		PollInterval:  r.inquiryInterval(TH),
+	}
+}
+
+// 计数加一
+func count(counter *uint64) {
+	atomic.AddUint64(counter, 1)
+}
+
+// 计数加 n
+func addCount(counter *uint64, n uint64) {
+	atomic.AddUint64(counter, n)
+}
@@ -0,0 +1,68 @@
+package relay
+
+// Fahrenheit 华氏温度。
+// 换算结果不做舍入，精度与设备上报的摄氏温度一致（整数位加小数位两字节），
+// 展示时请按需舍入，如 math.Round(v*10) / 10。
+func (t TemperatureAndHumidity) Fahrenheit() float64 {
+	return t.Temperature*9/5 + 32
+}
+
+// Kelvin 开尔文温度，精度说明同 Fahrenheit
+func (t TemperatureAndHumidity) Kelvin() float64 {
+	return t.Temperature + 273.15
+}
+
+// Calibration 温湿度校准配置，解析后分别加上偏移量再保存
+func Calibration(tempOffset, humidityOffset float64) Option {
+	return func(r *Relay) {
+		r.tempOffset = tempOffset
+		r.humidityOffset = humidityOffset
+	}
+}
+
+// ClampHumidity 校准后将湿度限制在 0~100 之间
+func ClampHumidity() Option {
+	return func(r *Relay) {
+		r.clampHumidity = true
+	}
+}
+
+// SmoothTH 温湿度指数移动平均配置，新值 = alpha*读数 + (1-alpha)*旧值，
+// alpha 取值 (0, 1]，1 表示不平滑（默认）。平滑在校准之后进行，结果不做舍入，
+// 平滑前的读数可从 Snapshot 的 RawTH 获取
+func SmoothTH(alpha float64) Option {
+	return func(r *Relay) {
+		r.smoothAlpha = alpha
+	}
+}
+
+// 指数移动平均
+func (r *Relay) smooth(old, th TemperatureAndHumidity) TemperatureAndHumidity {
+	r.cfgMu.RLock()
+	alpha := r.smoothAlpha
+	r.cfgMu.RUnlock()
+	if alpha <= 0 || alpha >= 1 {
+		return th
+	}
+	th.Temperature = alpha*th.Temperature + (1-alpha)*old.Temperature
+	th.Humidity = alpha*th.Humidity + (1-alpha)*old.Humidity
+	return th
+}
+
+// 校准温湿度
+func (r *Relay) calibrate(th TemperatureAndHumidity) TemperatureAndHumidity {
+	r.cfgMu.RLock()
+	tempOffset, humidityOffset, clamp := r.tempOffset, r.humidityOffset, r.clampHumidity
+	r.cfgMu.RUnlock()
+	th.Temperature += tempOffset
+	th.Humidity += humidityOffset
+	if clamp {
+		if th.Humidity < 0 {
+			th.Humidity = 0
+		}
+		if th.Humidity > 100 {
+			th.Humidity = 100
+		}
+	}
+	return th
+}
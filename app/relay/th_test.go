@@ -0,0 +1,43 @@
+package relay
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTemperatureConversion(t *testing.T) {
+	th := TemperatureAndHumidity{Temperature: 25}
+	if got := th.Fahrenheit(); got != 77 {
+		t.Fatalf("Fahrenheit = %v, want 77", got)
+	}
+	if got := th.Kelvin(); math.Abs(got-298.15) > 1e-9 {
+		t.Fatalf("Kelvin = %v, want 298.15", got)
+	}
+	th.Temperature = -40
+	if got := th.Fahrenheit(); got != -40 {
+		t.Fatalf("Fahrenheit = %v, want -40", got)
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	r := New(nil, nil, 0x1001, Calibration(-1.5, 5), ClampHumidity())
+	frame, _ := commandFormatter("A0 10 01 2A 01 19 05 61 00 00 00 00 A7")
+	r.SaveTH(frame)
+	th := r.TH()
+	if th.Temperature != 24 || th.Humidity != 100 {
+		t.Fatalf("calibrated th = %+v", th)
+	}
+}
+
+func TestSmoothTH(t *testing.T) {
+	r := New(nil, nil, 0x1001, KeepAlive(time.Second), SmoothTH(0.5))
+	frame, _ := commandFormatter(testFrames[2])
+	r.SaveTH(frame)
+	frame[5] = 0x1B // 27.5
+	r.SaveTH(frame)
+	s := r.Snapshot()
+	if s.TH.Temperature != 26.5 || s.RawTH.Temperature != 27.5 {
+		t.Fatalf("smoothed %v, raw %v", s.TH, s.RawTH)
+	}
+}
@@ -0,0 +1,159 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 默认的 Modbus 响应超时
+const modbusResponseTimeout = time.Second
+
+// 异常码 01：设备不支持该功能码
+const exceptionIllegalFunction byte = 0x01
+
+// ErrNotModbus 未配置 Modbus 分帧，无法读取 Modbus 响应
+var ErrNotModbus = errors.New("modbus framing required")
+
+// ModbusException Modbus 异常响应
+type ModbusException struct {
+	Function byte // 请求的功能码
+	Code     byte // 异常码
+}
+
+func (e *ModbusException) Error() string {
+	return fmt.Sprintf("modbus exception: function %02X, code %02X", e.Function, e.Code)
+}
+
+// 等待响应的 Modbus 请求，按从站地址与功能码（Modbus TCP 还有事务号）匹配响应。
+// 不同地址或功能码的请求可同时等待，相同的请求依次进行；Modbus TCP 每个请求的事务号不同，可同时等待
+type transactions struct {
+	mu      sync.Mutex
+	pending map[txKey]*transaction
+	lastID  uint16
+}
+
+// 请求与响应的匹配键
+type txKey struct {
+	addr byte
+	fn   byte
+	id   uint16 // MBAP 事务号，RTU 为 0
+}
+
+// 等待中的请求
+type transaction struct {
+	resp chan []byte
+	done chan struct{}
+}
+
+// 发送 Modbus 请求并等待匹配的响应，异常响应返回 *ModbusException
+func (r *Relay) call(ctx context.Context, req []byte) ([]byte, error) {
+	if r.framing == FramingVendor {
+		return nil, ErrNotModbus
+	}
+	key := txKey{addr: req[0], fn: req[1]}
+	frame := req
+	if r.framing == FramingTCP {
+		key.id = r.transactions.newID()
+		frame = mbapFrame(key.id, req)
+	}
+	tx, err := r.transactions.begin(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.transactions.end(key, tx)
+	if err := r.WriteFrameContext(ctx, frame); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-tx.resp:
+		if err := verifyResponseLength(resp); err != nil {
+			return nil, err
+		}
+		if resp[1]&0x80 != 0 {
+			return nil, &ModbusException{Function: req[1], Code: resp[2]}
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-r.closed:
+		return nil, ErrRelayClosed
+	}
+}
+
+// 校验响应长度：至少包含地址、功能码、一个字节和 CRC；
+// 读线圈、离散输入、寄存器（功能码 01~04）的长度须与字节数一致
+func verifyResponseLength(resp []byte) error {
+	if len(resp) < 5 {
+		return protocolErrorf(ErrShortFrame, "response too short: % X", resp)
+	}
+	if resp[1] >= FuncReadCoils && resp[1] <= FuncReadInputRegisters && len(resp) != 3+int(resp[2])+2 {
+		return protocolErrorf(ErrShortFrame, "response length %d does not match byte count %d: % X", len(resp), resp[2], resp)
+	}
+	return nil
+}
+
+// 登记请求，相同的请求仍在等待时阻塞到其结束
+func (t *transactions) begin(ctx context.Context, key txKey) (*transaction, error) {
+	for {
+		t.mu.Lock()
+		if t.pending == nil {
+			t.pending = make(map[txKey]*transaction)
+		}
+		prev, busy := t.pending[key]
+		if !busy {
+			tx := &transaction{resp: make(chan []byte, 1), done: make(chan struct{})}
+			t.pending[key] = tx
+			t.mu.Unlock()
+			return tx, nil
+		}
+		t.mu.Unlock()
+		select {
+		case <-prev.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 请求结束
+func (t *transactions) end(key txKey, tx *transaction) {
+	t.mu.Lock()
+	delete(t.pending, key)
+	t.mu.Unlock()
+	close(tx.done)
+}
+
+// 分配 MBAP 事务号
+func (t *transactions) newID() uint16 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastID++
+	return t.lastID
+}
+
+// 将 Modbus 响应交给匹配的请求，Modbus TCP 响应先转换为 RTU 帧布局
+func (r *Relay) deliver(frame []byte) {
+	var id uint16
+	if r.framing == FramingTCP {
+		id, frame = rtuFromMBAP(frame)
+	}
+	r.transactions.deliver(txKey{addr: frame[0], fn: frame[1] & 0x7F, id: id}, frame)
+}
+
+// 将响应交给匹配的请求，没有匹配的请求时丢弃
+func (t *transactions) deliver(key txKey, frame []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tx, ok := t.pending[key]
+	if !ok {
+		return
+	}
+	select {
+	case tx.resp <- frame:
+	default:
+	}
+}
@@ -0,0 +1,84 @@
+package relay
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotConfirmed 设备未在超时前确认输出状态
+var ErrNotConfirmed = errors.New("output not confirmed")
+
+// VerifyWrites 写入校验配置，SetOutput/SetOutputs 写入后确认设备的输出状态，
+// timeout 内未确认新值时返回 ErrNotConfirmed，本地输出状态不更新。
+// 默认协议没有输出状态查询命令，依赖设备在设置后回复输出状态帧；
+// Modbus 分帧写入后读回线圈（功能码 01）比较，SetOutputsAt 写其他从站时同样读回。
+func VerifyWrites(timeout time.Duration) Option {
+	return func(r *Relay) {
+		r.verifyTimeout = timeout
+	}
+}
+
+// 等待设备上报的输出状态与写入值一致
+func (r *Relay) confirmOutputs(ctx context.Context, confirm <-chan Property, values map[uint8]uint8) error {
+	timer := r.clock.NewTimer(r.verifyTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case value := <-confirm:
+			if outputsMatch(value.(OutputStates), values) {
+				return nil
+			}
+		case <-timer.C():
+			return ErrNotConfirmed
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.closed:
+			return ErrRelayClosed
+		}
+	}
+}
+
+// 读回 Modbus 线圈确认写入值，timeout 内未读到时返回 ErrNotConfirmed
+func (r *Relay) confirmCoils(ctx context.Context, addr uint16, values map[uint8]uint8) error {
+	routes := sortedRoutes(values)
+	if len(routes) == 0 {
+		return nil
+	}
+	first, last := routes[0], routes[len(routes)-1]
+	count := uint16(last-first) + 1
+	readCtx, cancel := context.WithTimeout(ctx, r.verifyTimeout)
+	defer cancel()
+	resp, err := r.call(readCtx, ReadCoilsFrame(addr, uint16(first-1), count))
+	if err != nil {
+		if ctx.Err() == nil && readCtx.Err() != nil {
+			return ErrNotConfirmed
+		}
+		return err
+	}
+	if int(resp[2]) < (int(count)+7)/8 {
+		return errors.Errorf("unexpected byte count %d", resp[2])
+	}
+	for _, route := range routes {
+		i := int(route - first)
+		if got := resp[3+i/8] >> uint(i%8) & 1; got != values[route] {
+			return errors.Wrapf(ErrNotConfirmed, "output %d is %d, want %d", route, got, values[route])
+		}
+	}
+	return nil
+}
+
+// 输出状态是否包含全部写入值
+func outputsMatch(states OutputStates, values map[uint8]uint8) bool {
+	matched := 0
+	for _, state := range states {
+		if value, ok := values[state.Route]; ok {
+			if value != state.Value {
+				return false
+			}
+			matched++
+		}
+	}
+	return matched == len(values)
+}
@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"context"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// WordOrderMode 32 位值在两个寄存器中的字节排列，ABCD 为值的字节从高到低
+type WordOrderMode int
+
+const (
+	// WordOrderABCD 高字在前，字内高字节在前（大端，Modbus 常见默认）
+	WordOrderABCD WordOrderMode = iota
+	// WordOrderCDAB 低字在前，字内高字节在前（字交换）
+	WordOrderCDAB
+	// WordOrderBADC 高字在前，字内低字节在前（字节交换）
+	WordOrderBADC
+	// WordOrderDCBA 低字在前，字内低字节在前（小端）
+	WordOrderDCBA
+)
+
+func (o WordOrderMode) String() string {
+	switch o {
+	case WordOrderABCD:
+		return "ABCD"
+	case WordOrderCDAB:
+		return "CDAB"
+	case WordOrderBADC:
+		return "BADC"
+	case WordOrderDCBA:
+		return "DCBA"
+	}
+	return "unknown"
+}
+
+// WordOrder 32 位寄存器值的字节排列配置，默认 WordOrderABCD，用于 ReadFloat32、ReadUint32。
+// 作用于按 ByteOrder 解析后的寄存器值，使用非默认 ByteOrder 时两者会叠加，通常只需配置其一
+func WordOrder(order WordOrderMode) Option {
+	return func(r *Relay) {
+		r.wordOrder = order
+	}
+}
+
+// DecodeUint32 将两个连续寄存器（regs[0] 为低地址）按 order 解析为 32 位无符号整数
+func DecodeUint32(regs [2]uint16, order WordOrderMode) uint32 {
+	hi, lo := regs[0], regs[1]
+	if order == WordOrderCDAB || order == WordOrderDCBA {
+		hi, lo = lo, hi
+	}
+	if order == WordOrderBADC || order == WordOrderDCBA {
+		hi, lo = swapBytes(hi), swapBytes(lo)
+	}
+	return uint32(hi)<<16 | uint32(lo)
+}
+
+// DecodeInt32 将两个连续寄存器按 order 解析为 32 位有符号整数
+func DecodeInt32(regs [2]uint16, order WordOrderMode) int32 {
+	return int32(DecodeUint32(regs, order))
+}
+
+// DecodeFloat32 将两个连续寄存器按 order 解析为 IEEE 754 单精度浮点数
+func DecodeFloat32(regs [2]uint16, order WordOrderMode) float32 {
+	return math.Float32frombits(DecodeUint32(regs, order))
+}
+
+// 交换寄存器的高低字节
+func swapBytes(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// ReadUint32 读从 reg 开始的两个保持寄存器并按 WordOrder 解析，超时 1 秒
+func (r *Relay) ReadUint32(reg uint16) (uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), modbusResponseTimeout)
+	defer cancel()
+	return r.ReadUint32Context(ctx, reg)
+}
+
+// ReadUint32Context 读从 reg 开始的两个保持寄存器并按 WordOrder 解析，ctx 控制写入与等待响应的时间
+func (r *Relay) ReadUint32Context(ctx context.Context, reg uint16) (uint32, error) {
+	regs, err := r.readRegisterPair(ctx, reg)
+	if err != nil {
+		return 0, errors.Wrap(err, "read uint32 failed")
+	}
+	return DecodeUint32(regs, r.wordOrder), nil
+}
+
+// ReadFloat32 读从 reg 开始的两个保持寄存器并按 WordOrder 解析为浮点数，超时 1 秒
+func (r *Relay) ReadFloat32(reg uint16) (float32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), modbusResponseTimeout)
+	defer cancel()
+	return r.ReadFloat32Context(ctx, reg)
+}
+
+// ReadFloat32Context 读从 reg 开始的两个保持寄存器并按 WordOrder 解析为浮点数，ctx 控制写入与等待响应的时间
+func (r *Relay) ReadFloat32Context(ctx context.Context, reg uint16) (float32, error) {
+	regs, err := r.readRegisterPair(ctx, reg)
+	if err != nil {
+		return 0, errors.Wrap(err, "read float32 failed")
+	}
+	return DecodeFloat32(regs, r.wordOrder), nil
+}
+
+// 读两个连续的保持寄存器
+func (r *Relay) readRegisterPair(ctx context.Context, reg uint16) ([2]uint16, error) {
+	resp, err := r.call(ctx, ReadHoldingRegistersFrame(r.SubDeviceID, reg, 2))
+	if err != nil {
+		return [2]uint16{}, err
+	}
+	if resp[2] != 4 {
+		return [2]uint16{}, errors.Errorf("unexpected byte count %d", resp[2])
+	}
+	values := r.decodeRegisters(resp[3:7])
+	return [2]uint16{values[0], values[1]}, nil
+}
@@ -1,34 +1,101 @@
 package relay
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-// WriteFn 向 conn 写入的方法集合
+// WriteFn 向 conn 写入的方法集合，fn 返回写入错误；interval 不为空时每次按其返回值计算间隔
 type WriteFn struct {
-	d  time.Duration
-	fn func()
+	d        time.Duration
+	interval func() time.Duration
+	fn       func() error
+	t        PropertyType // 默认协议询问的属性类型，熔断器据此判断上一次询问是否已响应
 }
 
-// WriteLoop 开启N个协程，向连接循环发送命令
+// 本次间隔
+func (wf WriteFn) delay() time.Duration {
+	if wf.interval != nil {
+		return wf.interval()
+	}
+	return wf.d
+}
+
+// MaxWriteFailures 连续写入失败次数上限，n <= 0 不限制（默认）。
+// 写入错误总会发送到 Errors 通道；连续失败 n 次后关闭当前连接，
+// 由读取循环按读取失败处理（配置了 Reconnect 时重连，否则下线），挂在总线上或配置了 NotOwnConn 时直接下线。
+func MaxWriteFailures(n int) Option {
+	return func(r *Relay) {
+		r.maxWriteFailures = n
+	}
+}
+
+// WriteLoop 开启N个协程，向连接循环发送命令；配置了 WithScheduler 时注册到共享调度器
 func (r *Relay) WriteLoop(wfs []WriteFn) error {
-	if r.Conn == nil {
-		return errors.Wrap(errors.New("not connected"), "write data failed")
+	if r.conn() == nil {
+		return errors.Wrap(ErrNotConnected, "write data failed")
+	}
+	if r.scheduler != nil {
+		for _, wf := range wfs {
+			r.scheduler.add(r, wf)
+		}
+		return nil
 	}
 	for _, wf := range wfs {
-		go func(wf WriteFn) {
+		wf := wf
+		r.goLoop(func() {
+			if delay := r.initialDelay(wf.delay()); delay > 0 {
+				timer := r.clock.NewTimer(delay)
+				select {
+				case <-r.closed:
+					timer.Stop()
+					return
+				case <-r.ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C():
+				}
+			}
 			for {
+				r.poll(wf)
+				retick := r.retickChan()
+				timer := r.clock.NewTimer(r.jittered(wf.delay()))
 				select {
 				case <-r.closed:
+					timer.Stop()
 					return
-				default:
-					wf.fn()
-					time.Sleep(wf.d)
+				case <-r.ctx.Done():
+					timer.Stop()
+					return
+				case <-retick:
+					timer.Stop()
+				case <-timer.C():
 				}
 			}
-		}(wf)
+		})
 	}
 	return nil
 }
+
+// 统计连续写入失败，达到上限时断开连接
+func (r *Relay) writeResult(err error) {
+	if err == nil {
+		atomic.StoreInt32(&r.writeFailures, 0)
+		return
+	}
+	n := atomic.AddInt32(&r.writeFailures, 1)
+	if r.maxWriteFailures <= 0 || int(n) < r.maxWriteFailures {
+		return
+	}
+	atomic.StoreInt32(&r.writeFailures, 0)
+	r.log().Printf("设备 %d 连续 %d 次写入失败", r.SubDeviceID, n)
+	err = errors.Errorf("write failed %d times in a row", n)
+	r.reportError(err)
+	if r.onBus || !r.ownConn {
+		r.offlineWith(OfflineWriteFailures, err)
+		return
+	}
+	r.conn().Close()
+}
@@ -0,0 +1,26 @@
+package relay
+
+import "github.com/pkg/errors"
+
+// WriteMiddleware 写入中间件，帧写入连接前处理，可记录或改写帧（如改写从站地址），改写时应返回新的切片而不是修改传入的帧。
+// 与处理接收数据的 Middleware 相互独立；返回 error 时终止执行，本次写入不发送并返回该错误
+type WriteMiddleware func(r *Relay, frame []byte) ([]byte, error)
+
+// WriteMiddlewares 写入中间件配置，按传入顺序作用于 SetOutput、查询等产生的全部帧，
+// Modbus TCP 时处理的是带 MBAP 头的完整帧
+func WriteMiddlewares(middlewares ...WriteMiddleware) Option {
+	return func(r *Relay) {
+		r.writeMiddlewares = middlewares
+	}
+}
+
+// 依次执行写入中间件
+func (r *Relay) processWrite(frame []byte) ([]byte, error) {
+	for _, mw := range r.writeMiddlewares {
+		var err error
+		if frame, err = mw(r, frame); err != nil {
+			return nil, errors.Wrap(err, "write middleware")
+		}
+	}
+	return frame, nil
+}